@@ -0,0 +1,151 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prytoegrian/swapi/database"
+)
+
+// Config gathers the settings of the server
+type Config struct {
+	Server   Server
+	Database database.Config
+	// Debug is the log level : 0 logs only failures and lifecycle, 1 each request, 2 each SQL statement too
+	Debug int
+	// LogFormat is text, for people, or json, for log collectors
+	LogFormat string
+	// OnDelete tells what becomes of the links of a deleted people
+	OnDelete string
+}
+
+// Server tells where and how the server listens
+type Server struct {
+	// Addr is the TCP address to listen on, such as :8080
+	Addr string
+	// Socket is the path of a Unix socket to listen on instead of Addr, if any
+	Socket string
+	// TLSCert and TLSKey are the files of the certificate and key to serve HTTPS with, if any
+	TLSCert      string
+	TLSKey       string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long requests in flight may take to end once the server is asked to stop
+	ShutdownTimeout time.Duration
+}
+
+// TLS tells if the server serves HTTPS
+func (s Server) TLS() bool {
+	return s.TLSCert != ""
+}
+
+// Load reads the settings from the command line arguments. A setting missing there is read from
+// its environment variable (SWAPI_ followed by the flag name, such as SWAPI_DB_JOURNAL_MODE), then from
+// the JSON config file given by -config, whose keys are the flag names, and defaults otherwise
+func Load(args []string) (Config, error) {
+	var c Config
+	var file string
+	fs := flag.NewFlagSet("swapi", flag.ContinueOnError)
+	fs.StringVar(&file, "config", "", "JSON config file, keyed by flag names")
+	fs.StringVar(&c.Server.Addr, "addr", ":8080", "TCP address to listen on")
+	fs.StringVar(&c.Server.Socket, "socket", "", "Unix socket to listen on instead of addr")
+	fs.StringVar(&c.Server.TLSCert, "tls-cert", "", "Certificate file to serve HTTPS with, along with tls-key")
+	fs.StringVar(&c.Server.TLSKey, "tls-key", "", "Key file to serve HTTPS with, along with tls-cert")
+	fs.DurationVar(&c.Server.ReadTimeout, "read-timeout", 10*time.Second, "How long to read a request")
+	fs.DurationVar(&c.Server.WriteTimeout, "write-timeout", 30*time.Second, "How long to write a response")
+	fs.DurationVar(&c.Server.IdleTimeout, "idle-timeout", 2*time.Minute, "How long to keep an idle connection")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", 15*time.Second, "How long to wait for requests in flight when stopping")
+	fs.StringVar(&c.Database.Path, "db", "database/swapi.dat", "Path of the SQLite database")
+	fs.DurationVar(&c.Database.BusyTimeout, "db-busy-timeout", 5*time.Second, "How long to wait for a locked database")
+	fs.StringVar(&c.Database.JournalMode, "db-journal-mode", "", "SQLite journal mode, such as WAL. Empty keeps the database one")
+	fs.BoolVar(&c.Database.ReadOnly, "db-read-only", false, "Open the database read-only")
+	fs.IntVar(&c.Debug, "debug", 0, "Log level : 0 failures only, 1 requests, 2 requests and SQL statements")
+	fs.StringVar(&c.LogFormat, "log-format", "text", "Log format : text or json")
+	fs.StringVar(&c.OnDelete, "on-delete", "cascade", "Links of a deleted people : cascade or restrict")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if !given["config"] {
+		file = os.Getenv(env("config"))
+	}
+	values, err := read(file)
+	if err != nil {
+		return Config{}, err
+	}
+	for name := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return Config{}, errors.New("Unknown setting " + name + " in config file " + file)
+		}
+	}
+
+	var failed error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || failed != nil {
+			return
+		}
+		v, ok := os.LookupEnv(env(f.Name))
+		if !ok {
+			v, ok = values[f.Name]
+		}
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, v); err != nil {
+			failed = errors.New("Invalid value " + v + " for " + f.Name + " : " + err.Error())
+		}
+	})
+
+	if failed != nil {
+		return Config{}, failed
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		return Config{}, errors.New("TLS needs both tls-cert and tls-key")
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return Config{}, errors.New("Unknown log format " + c.LogFormat + ", expected text or json")
+	}
+
+	return c, nil
+}
+
+// env names the environment variable of a flag
+func env(name string) string {
+	return "SWAPI_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// read gives the settings of a config file, if any
+func read(file string) (map[string]string, error) {
+	values := make(map[string]string)
+	if file == "" {
+		return values, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.New("Config file " + file + " unreadable : " + err.Error())
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, errors.New("Config file " + file + " malformed : " + err.Error())
+	}
+	for name, v := range raw {
+		m, _ := json.Marshal(v)
+		if s, ok := v.(string); ok {
+			values[name] = s
+		} else {
+			values[name] = string(m)
+		}
+	}
+
+	return values, nil
+}
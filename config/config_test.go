@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load(nil)
+	if err != nil || c.Server.Addr != ":8080" || c.Server.TLS() || c.Database.Path != "database/swapi.dat" || c.Database.BusyTimeout != 5*time.Second || c.Server.ShutdownTimeout != 15*time.Second || c.OnDelete != "cascade" {
+		t.Error("Wrong defaults")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swapi.json")
+	content := `{"db": "/from/file.dat", "db-read-only": true, "db-journal-mode": "WAL"}`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SWAPI_DB_JOURNAL_MODE", "MEMORY")
+	t.Setenv("SWAPI_DB", "/from/env.dat")
+
+	c, err := Load([]string{"-config", file, "-db", "/from/flag.dat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Database.Path != "/from/flag.dat" {
+		t.Error("Flag does not win : " + c.Database.Path)
+	}
+	if c.Database.JournalMode != "MEMORY" {
+		t.Error("Environment does not win over file : " + c.Database.JournalMode)
+	}
+	if !c.Database.ReadOnly {
+		t.Error("File not read")
+	}
+}
+
+func TestLoadUnknownSetting(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swapi.json")
+	if err := os.WriteFile(file, []byte(`{"database": "swapi.dat"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load([]string{"-config", file}); err == nil {
+		t.Error("Unknown setting accepted")
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	t.Setenv("SWAPI_DB_BUSY_TIMEOUT", "soon")
+	if _, err := Load(nil); err == nil {
+		t.Error("Invalid duration accepted")
+	}
+}
+
+func TestLoadTLSHalfConfigured(t *testing.T) {
+	if _, err := Load([]string{"-tls-cert", "swapi.crt"}); err == nil {
+		t.Error("Certificate without key accepted")
+	}
+}
+
+func TestLoadUnknownLogFormat(t *testing.T) {
+	if _, err := Load([]string{"-log-format", "xml"}); err == nil {
+		t.Error("Unknown log format accepted")
+	}
+}
@@ -1,26 +1,127 @@
 package database
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bvinc/go-sqlite-lite/sqlite3"
+	"github.com/prytoegrian/swapi/failure"
 )
 
 // Database describes accesses to a storage
 type Database interface {
 	Prepare(string, ...interface{}) (Stmt, error)
+	Insert(table string, columns []string, values ...interface{}) (int, error)
+	WithTx(func(Database) error) error
+	WithLogger(*slog.Logger) Database
+	Close() error
 }
 
-// Db represents a connection to the storage
+// Db represents the connections to the storage : a read-only one, shared by all requests, and a writer one,
+// dedicated to transactions, so that statements of other requests never run within a transaction
 type Db struct {
 	sqlite *sqlite3.Conn
+	writer *sqlite3.Conn
+	// txs serialises transactions, the writer connection running one at a time
+	txs *sync.Mutex
+	// trace logs every statement
+	trace bool
+	log   *slog.Logger
 }
 
-// Prepare encapsulates the inner connection for testability
+// Prepare encapsulates the read-only connection for testability. Writes go through Exec or WithTx
 func (d Db) Prepare(sql string, args ...interface{}) (Stmt, error) {
-	return d.sqlite.Prepare(sql, args...)
+	return prepare(d.sqlite, d.trace, d.log, sql, args)
+}
+
+// prepare compiles a statement, traced into l if asked
+func prepare(s *sqlite3.Conn, traces bool, l *slog.Logger, sql string, args []interface{}) (Stmt, error) {
+	stmt, err := s.Prepare(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if !traces {
+		return stmt, nil
+	}
+
+	return trace(stmt, l, sql, args), nil
+}
+
+// Insert stores a row into table, allocating its id within an immediate transaction
+func (d Db) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	var id int
+	err := d.WithTx(func(t Database) error {
+		var err error
+		id, err = t.Insert(table, columns, values...)
+
+		return err
+	})
+
+	return id, err
+}
+
+// WithTx runs f within an immediate transaction on the writer connection, committed if f succeeds and rolled back otherwise.
+// A panicking f rolls the transaction back too before panicking further, lest the writer connection stays within it
+func (d Db) WithTx(f func(Database) error) error {
+	d.txs.Lock()
+	defer d.txs.Unlock()
+
+	if err := d.writer.BeginImmediate(); err != nil {
+		return failure.Unavailable("Failed to begin transaction", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			d.writer.Rollback()
+			panic(p)
+		}
+	}()
+	if err := f(tx{sqlite: d.writer, trace: d.trace, log: d.log}); err != nil {
+		d.writer.Rollback()
+		return err
+	}
+	if err := d.writer.Commit(); err != nil {
+		d.writer.Rollback()
+		return failure.Unavailable("Failed to commit transaction", err)
+	}
+
+	return nil
+}
+
+// WithLogger gives a database tracing its statements into l, such as the logger of a request
+func (d Db) WithLogger(l *slog.Logger) Database {
+	d.log = l
+
+	return d
+}
+
+// Close releases the connections, once the running transaction, if any, is over
+func (d Db) Close() error {
+	d.txs.Lock()
+	defer d.txs.Unlock()
+
+	err := d.sqlite.Close()
+	if werr := d.writer.Close(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return failure.Unavailable("Failed to close", err)
+	}
+
+	return nil
+}
+
+// execFailure qualifies a failed write, a broken constraint being a conflict with stored data
+func execFailure(err error) error {
+	var e *sqlite3.Error
+	if errors.As(err, &e) && e.Code() == sqlite3.CONSTRAINT {
+		return &failure.Error{Kind: failure.ErrConflict, Message: "Conflicting row", Cause: err}
+	}
+
+	return failure.Unavailable("Failed to exec SQL", err)
 }
 
 // Stmt represents a query statement
@@ -32,15 +133,160 @@ type Stmt interface {
 	Scan(dst ...interface{}) error
 }
 
-// NewDb initialise a new connection
-func NewDb() Database {
-	s, err := sqlite3.Open(os.Getenv("GOPATH") + "/src/github.com/prytoegrian/swapi/database/swapi.dat")
+// Config tells where the storage lies and how to open it
+type Config struct {
+	Path        string
+	BusyTimeout time.Duration
+	// JournalMode is one of the SQLite journal modes, such as WAL. Empty keeps the file's one
+	JournalMode string
+	ReadOnly    bool
+	// Trace logs every statement, with its arguments, rows and timing
+	Trace bool
+}
+
+// sequences keeps, for each table, the last id allocated
+const sequences = `CREATE TABLE IF NOT EXISTS sequences (name TEXT PRIMARY KEY, id INTEGER NOT NULL)`
+
+// journalModes are the journal modes SQLite knows
+var journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+
+// NewDb initialise a new connection, failing if the storage file is missing or cannot be opened
+func NewDb(c Config) (Database, error) {
+	if _, err := os.Stat(c.Path); err != nil {
+		return nil, errors.New("Database " + c.Path + " not found : " + err.Error())
+	}
+	mode := strings.ToUpper(c.JournalMode)
+	if mode != "" && !known(journalModes, mode) {
+		return nil, errors.New("Unknown journal mode " + c.JournalMode + ", expected one of " + strings.Join(journalModes, ", "))
+	}
+
+	flags := sqlite3.OPEN_READWRITE
+	if c.ReadOnly {
+		flags = sqlite3.OPEN_READONLY
+	}
+	s, err := sqlite3.Open(c.Path, flags)
+	if err != nil {
+		return nil, errors.New("Database " + c.Path + " cannot be opened : " + err.Error())
+	}
+	s.BusyTimeout(c.BusyTimeout)
+
+	if mode != "" {
+		if err := pragma(s, "journal_mode = "+mode); err != nil {
+			s.Close()
+			return nil, errors.New("Journal mode " + mode + " cannot be set : " + err.Error())
+		}
+	}
+	if !c.ReadOnly {
+		if err := s.Exec(sequences); err != nil {
+			s.Close()
+			return nil, errors.New("Sequences cannot be created : " + err.Error())
+		}
+	}
+
+	reader, err := sqlite3.Open(c.Path, sqlite3.OPEN_READONLY)
 	if err != nil {
-		log.Fatal(err)
+		s.Close()
+		return nil, errors.New("Database " + c.Path + " cannot be opened : " + err.Error())
 	}
-	s.BusyTimeout(5 * time.Second)
+	reader.BusyTimeout(c.BusyTimeout)
 
 	return Db{
-		sqlite: s,
+		sqlite: reader,
+		writer: s,
+		txs:    &sync.Mutex{},
+		trace:  c.Trace,
+	}, nil
+}
+
+// pragma runs a PRAGMA statement, ignoring the row it may give
+func pragma(s *sqlite3.Conn, p string) error {
+	stmt, err := s.Prepare(`PRAGMA ` + p)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Step()
+
+	return err
+}
+
+func known(l []string, s string) bool {
+	for _, item := range l {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Exec prepares and executes a statement which returns no row, within the running transaction if any,
+// within a transaction of its own otherwise
+func Exec(db Database, sql string, args ...interface{}) error {
+	return db.WithTx(func(t Database) error {
+		stmt, err := t.Prepare(sql)
+		if err != nil {
+			return failure.Unavailable("Failed to prepare", err)
+		}
+		defer stmt.Close()
+
+		if err = stmt.Exec(args...); err != nil {
+			return execFailure(err)
+		}
+
+		return nil
+	})
+}
+
+// nextID allocates the id of the next row stored into table, within the running transaction.
+// Ids only go up : the last one allocated is kept in the sequences table, so that the id of a deleted row is never given again
+func nextID(db Database, table string) (int, error) {
+	last, err := Count(db, `SELECT MAX(COALESCE((SELECT MAX(CAST(id AS INTEGER)) FROM `+table+`), 0),
+            COALESCE((SELECT id FROM sequences WHERE name = ?), 0))`, table)
+	if err != nil {
+		return 0, err
+	}
+	id := last + 1
+	if err := Exec(db, `INSERT OR REPLACE INTO sequences (name, id) VALUES (?, ?)`, table, id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// In builds the parameterised list of an IN condition, along with its arguments
+func In(ids []int) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "()", nil
+	}
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	return "(?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
+
+// Count runs a counting query, returning its single value
+func Count(db Database, sql string, args ...interface{}) (int, error) {
+	stmt, err := db.Prepare(sql, args...)
+	if err != nil {
+		return 0, failure.Unavailable("Failed to prepare", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return 0, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return 0, nil
 	}
+	var c int
+	if err := stmt.Scan(&c); err != nil {
+		return 0, failure.Unavailable("Scan gave error", err)
+	}
+
+	return c, nil
 }
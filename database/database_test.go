@@ -0,0 +1,113 @@
+package database
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNewDbMissing(t *testing.T) {
+	if _, err := NewDb(Config{Path: "missing.dat"}); err == nil {
+		t.Error("Missing database opened")
+	}
+}
+
+func TestNewDbUnknownJournalMode(t *testing.T) {
+	if _, err := NewDb(Config{Path: "swapi.dat", JournalMode: "fast"}); err == nil {
+		t.Error("Unknown journal mode accepted")
+	}
+}
+
+func TestWithTxPanicking(t *testing.T) {
+	b, err := os.ReadFile("swapi.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "swapi.dat")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDb(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Panic swallowed")
+			}
+		}()
+		db.WithTx(func(Database) error {
+			panic("handler bug")
+		})
+	}()
+
+	if err := db.WithTx(func(Database) error { return nil }); err != nil {
+		t.Error("Transaction left open : " + err.Error())
+	}
+}
+
+func TestIn(t *testing.T) {
+	if in, args := In([]int{1, 2, 3}); in != "(?, ?, ?)" || len(args) != 3 {
+		t.Error("Wrong list : " + in)
+	}
+}
+
+// SequenceDouble is a database whose counting queries give last, recording the arguments of executed statements
+type SequenceDouble struct {
+	last int
+	exec *[]interface{}
+}
+
+type SequenceStmtDouble struct {
+	SequenceDouble
+}
+
+func (d SequenceDouble) Prepare(sql string, args ...interface{}) (Stmt, error) {
+	return SequenceStmtDouble{d}, nil
+}
+
+func (d SequenceDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 0, nil
+}
+
+func (d SequenceDouble) WithTx(f func(Database) error) error {
+	return f(d)
+}
+
+func (d SequenceDouble) WithLogger(*slog.Logger) Database {
+	return d
+}
+
+func (d SequenceDouble) Close() error {
+	return nil
+}
+
+func (s SequenceStmtDouble) Step() (bool, error) {
+	return true, nil
+}
+
+func (s SequenceStmtDouble) Exec(args ...interface{}) error {
+	*s.exec = args
+	return nil
+}
+
+func (s SequenceStmtDouble) Scan(dst ...interface{}) error {
+	*dst[0].(*int) = s.last
+	return nil
+}
+
+func TestNextIDRemembered(t *testing.T) {
+	var exec []interface{}
+	id, err := nextID(SequenceDouble{last: 7, exec: &exec}, "people")
+	if err != nil || id != 8 {
+		t.Fatal("Wrong id : " + strconv.Itoa(id))
+	}
+	if len(exec) != 2 || exec[0] != "people" || exec[1] != 8 {
+		t.Error("Id not remembered")
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+// DefaultLimit is the number of rows in a page when none is asked
+const DefaultLimit = 10
+
+// MaxLimit is the highest number of rows a page may hold
+const MaxLimit = 100
+
+// Page describes a window over a list of rows, numbered from 1
+type Page struct {
+	Number int
+	Limit  int
+}
+
+// NewPage initialises a page, falling back on defaults for zero values
+func NewPage(number int, limit int) Page {
+	if number < 1 {
+		number = 1
+	}
+	if limit < 1 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	return Page{
+		Number: number,
+		Limit:  limit,
+	}
+}
+
+// Offset is the number of rows to skip before the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.Limit
+}
+
+// HasNext tells if rows remain after the page, given the total count
+func (p Page) HasNext(count int) bool {
+	return p.Offset()+p.Limit < count
+}
+
+// HasPrevious tells if rows exist before the page
+func (p Page) HasPrevious() bool {
+	return p.Number > 1
+}
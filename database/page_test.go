@@ -0,0 +1,43 @@
+package database
+
+import "testing"
+
+func TestNewPageDefaults(t *testing.T) {
+	p := NewPage(0, 0)
+	if p.Number != 1 || p.Limit != DefaultLimit {
+		t.Error("Defaults not applied")
+	}
+}
+
+func TestNewPageMaxLimit(t *testing.T) {
+	p := NewPage(2, MaxLimit+1)
+	if p.Limit != MaxLimit {
+		t.Error("Limit not capped")
+	}
+}
+
+func TestPageOffset(t *testing.T) {
+	p := NewPage(3, 10)
+	if p.Offset() != 20 {
+		t.Error("Wrong offset")
+	}
+}
+
+func TestPageHasNext(t *testing.T) {
+	p := NewPage(2, 10)
+	if !p.HasNext(21) {
+		t.Error("There's a next page")
+	}
+	if p.HasNext(20) {
+		t.Error("There's no next page")
+	}
+}
+
+func TestPageHasPrevious(t *testing.T) {
+	if NewPage(1, 10).HasPrevious() {
+		t.Error("There's no previous page")
+	}
+	if !NewPage(2, 10).HasPrevious() {
+		t.Error("There's a previous page")
+	}
+}
@@ -0,0 +1,21 @@
+package database
+
+import "log/slog"
+
+// Repository is a repository of type R working against a database, able to give itself working against another one
+type Repository[R any] interface {
+	Database() Database
+	WithDatabase(Database) R
+}
+
+// Atomically runs f against r bound to a transaction, so that the writes of f are all or nothing
+func Atomically[R Repository[R]](r R, f func(R) error) error {
+	return r.Database().WithTx(func(tx Database) error {
+		return f(r.WithDatabase(tx))
+	})
+}
+
+// WithLogger gives r tracing its statements into l, such as the logger of a request
+func WithLogger[R Repository[R]](r R, l *slog.Logger) R {
+	return r.WithDatabase(r.Database().WithLogger(l))
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"log/slog"
+	"testing"
+)
+
+// RepositoryDouble remembers the database it works against
+type RepositoryDouble struct {
+	db Database
+}
+
+func (r RepositoryDouble) Database() Database {
+	return r.db
+}
+
+func (r RepositoryDouble) WithDatabase(db Database) RepositoryDouble {
+	r.db = db
+
+	return r
+}
+
+// TxDouble runs transactions against a tx
+type TxDouble struct {
+	SequenceDouble
+}
+
+func (d TxDouble) WithTx(f func(Database) error) error {
+	return f(tx{})
+}
+
+func TestAtomicallyBound(t *testing.T) {
+	Atomically(RepositoryDouble{db: TxDouble{}}, func(r RepositoryDouble) error {
+		if _, ok := r.db.(tx); !ok {
+			t.Error("Repository not bound to the transaction")
+		}
+		return nil
+	})
+}
+
+func TestWithLoggerBound(t *testing.T) {
+	l := slog.Default()
+	r := WithLogger(RepositoryDouble{db: tx{}}, l)
+	if r.db.(tx).log != l {
+		t.Error("Repository not bound to the logger")
+	}
+}
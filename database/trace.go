@@ -0,0 +1,60 @@
+package database
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// traced is a statement logging, once closed, its SQL, arguments, rows stepped through and timing
+type traced struct {
+	Stmt
+	log   *slog.Logger
+	sql   string
+	args  []interface{}
+	rows  int
+	start time.Time
+}
+
+// trace wraps a statement freshly prepared so that it is logged into l, the default logger if nil
+func trace(s Stmt, l *slog.Logger, sql string, args []interface{}) Stmt {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &traced{Stmt: s, log: l, sql: sql, args: args, start: time.Now()}
+}
+
+// Step counts the rows given
+func (t *traced) Step() (bool, error) {
+	hasRow, err := t.Stmt.Step()
+	if hasRow {
+		t.rows++
+	}
+
+	return hasRow, err
+}
+
+// Exec remembers the arguments bound when executing
+func (t *traced) Exec(args ...interface{}) error {
+	t.args = append(t.args, args...)
+
+	return t.Stmt.Exec(args...)
+}
+
+// Close logs the statement, at debug level
+func (t *traced) Close() error {
+	err := t.Stmt.Close()
+	args := t.args
+	if args == nil {
+		args = make([]interface{}, 0)
+	}
+	t.log.Debug("SQL statement",
+		"sql", strings.Join(strings.Fields(t.sql), " "),
+		"args", args,
+		"rows", t.rows,
+		"duration", time.Since(t.start),
+	)
+
+	return err
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type StmtDouble struct {
+	rows int
+}
+
+func (s *StmtDouble) Close() error {
+	return nil
+}
+
+func (s *StmtDouble) Step() (bool, error) {
+	s.rows--
+
+	return s.rows >= 0, nil
+}
+
+func (s *StmtDouble) Exec(...interface{}) error {
+	return nil
+}
+
+func (s *StmtDouble) Scan(dst ...interface{}) error {
+	return nil
+}
+
+// traceInto gives a JSON logger writing into b, at debug level
+func traceInto(b *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestTraceRows(t *testing.T) {
+	var b bytes.Buffer
+	stmt := trace(&StmtDouble{rows: 2}, traceInto(&b), "SELECT id\n        FROM people WHERE id IN (?, ?)", []interface{}{1, "2"})
+	for {
+		hasRow, _ := stmt.Step()
+		if !hasRow {
+			break
+		}
+	}
+	stmt.Close()
+
+	line := b.String()
+	if !strings.Contains(line, `"sql":"SELECT id FROM people WHERE id IN (?, ?)","args":[1,"2"],"rows":2,"duration":`) {
+		t.Error("Wrong trace : " + line)
+	}
+}
+
+func TestTraceExec(t *testing.T) {
+	var b bytes.Buffer
+	stmt := trace(&StmtDouble{}, traceInto(&b), "DELETE FROM people WHERE id = ?", nil)
+	stmt.Exec(4)
+	stmt.Close()
+
+	line := b.String()
+	if !strings.Contains(line, `"level":"DEBUG","msg":"SQL statement","sql":"DELETE FROM people WHERE id = ?","args":[4],"rows":0`) {
+		t.Error("Wrong trace : " + line)
+	}
+}
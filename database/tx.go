@@ -0,0 +1,54 @@
+package database
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/bvinc/go-sqlite-lite/sqlite3"
+)
+
+// tx represents the connection to the storage while a transaction runs on it
+type tx struct {
+	sqlite *sqlite3.Conn
+	trace  bool
+	log    *slog.Logger
+}
+
+// Prepare encapsulates the inner connection for testability
+func (t tx) Prepare(sql string, args ...interface{}) (Stmt, error) {
+	return prepare(t.sqlite, t.trace, t.log, sql, args)
+}
+
+// Insert stores a row into table, allocating its id
+func (t tx) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	id, err := nextID(t, table)
+	if err != nil {
+		return 0, err
+	}
+
+	err = Exec(t, `INSERT INTO `+table+`
+        (id, `+strings.Join(columns, ", ")+`)
+        VALUES (?`+strings.Repeat(", ?", len(columns))+`)`, append([]interface{}{id}, values...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// WithTx runs f within the running transaction, which stays in charge of commit and rollback
+func (t tx) WithTx(f func(Database) error) error {
+	return f(t)
+}
+
+// WithLogger gives the transaction tracing its statements into l
+func (t tx) WithLogger(l *slog.Logger) Database {
+	t.log = l
+
+	return t
+}
+
+// Close leaves the connection open, its owner closing it once the transaction is over
+func (t tx) Close() error {
+	return nil
+}
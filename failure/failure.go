@@ -0,0 +1,105 @@
+package failure
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotFound means the resource asked does not exist
+	ErrNotFound = errors.New("Not found")
+	// ErrConflict means the operation clashes with the current state of storage
+	ErrConflict = errors.New("Conflict")
+	// ErrValidation means the data given are not acceptable
+	ErrValidation = errors.New("Validation failed")
+	// ErrPrecondition means the resource no longer is in the state the operation expects
+	ErrPrecondition = errors.New("Precondition failed")
+	// ErrUnavailable means the storage could not fulfil the operation
+	ErrUnavailable = errors.New("Storage unavailable")
+)
+
+// Error is a failure of a given kind, explained by a message
+type Error struct {
+	Kind    error
+	Message string
+	// Fields explains, field by field, what is wrong with the data given
+	Fields map[string]string
+	Cause  error
+}
+
+// Error describes the failure
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + " : " + e.Cause.Error()
+	}
+
+	return e.Message
+}
+
+// Is makes the failure match its kind, cf. errors.Is
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap gives the underlying cause, if any
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// NotFound signals an unknown resource
+func NotFound(resource string, id int) error {
+	return &Error{
+		Kind:    ErrNotFound,
+		Message: resource + " #" + strconv.Itoa(id) + " not found",
+	}
+}
+
+// Conflict signals an operation clashing with storage
+func Conflict(message string) error {
+	return &Error{
+		Kind:    ErrConflict,
+		Message: message,
+	}
+}
+
+// Precondition signals a resource changed since the client last read it
+func Precondition(message string) error {
+	return &Error{
+		Kind:    ErrPrecondition,
+		Message: message,
+	}
+}
+
+// Validation signals unacceptable data
+func Validation(message string) error {
+	return &Error{
+		Kind:    ErrValidation,
+		Message: message,
+	}
+}
+
+// Invalid signals unacceptable data, explaining each wrong field
+func Invalid(fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &Error{
+		Kind:    ErrValidation,
+		Message: "Invalid " + strings.Join(names, ", "),
+		Fields:  fields,
+	}
+}
+
+// Unavailable signals a storage failure, keeping its cause
+func Unavailable(message string, cause error) error {
+	return &Error{
+		Kind:    ErrUnavailable,
+		Message: message,
+		Cause:   cause,
+	}
+}
@@ -0,0 +1,54 @@
+package failure
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNotFoundIs(t *testing.T) {
+	err := NotFound("People", 3)
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Not a not found failure")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Error("Not found is a conflict")
+	}
+	if err.Error() != "People #3 not found" {
+		t.Error("Wrong message : " + err.Error())
+	}
+}
+
+func TestUnavailableCause(t *testing.T) {
+	cause := errors.New("database is locked")
+	err := Unavailable("Step gave error", cause)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Error("Not an unavailable failure")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Cause lost")
+	}
+	if err.Error() != "Step gave error : database is locked" {
+		t.Error("Wrong message : " + err.Error())
+	}
+}
+
+func TestValidationAs(t *testing.T) {
+	var f *Error
+	if !errors.As(Validation("Unknown filter"), &f) || f.Message != "Unknown filter" {
+		t.Error("Not a failure")
+	}
+}
+
+func TestInvalidFields(t *testing.T) {
+	err := Invalid(map[string]string{"name": "Required", "gender": "Unknown"})
+	if !errors.Is(err, ErrValidation) {
+		t.Error("Not a validation failure")
+	}
+	var f *Error
+	if !errors.As(err, &f) || f.Fields["name"] != "Required" {
+		t.Error("Fields lost")
+	}
+	if err.Error() != "Invalid gender, name" {
+		t.Error("Wrong message : " + err.Error())
+	}
+}
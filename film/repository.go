@@ -0,0 +1,300 @@
+package film
+
+import (
+	"time"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/people"
+	"github.com/prytoegrian/swapi/planet"
+	"github.com/prytoegrian/swapi/species"
+	"github.com/prytoegrian/swapi/starship"
+	"github.com/prytoegrian/swapi/vehicle"
+)
+
+// NewRepo initialises a new film repository
+func NewRepo(db d.Database) Repository {
+	return Repository{
+		db: db,
+	}
+}
+
+// Repository is a film repository
+type Repository struct {
+	db d.Database
+}
+
+// Database is the database the film repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the film repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
+}
+
+// Film represents a well-formed film
+type Film struct {
+	ID           int                 `json:"id"`
+	Title        string              `json:"title"`
+	EpisodeID    int                 `json:"episode_id"`
+	OpeningCrawl string              `json:"opening_crawl"`
+	Director     string              `json:"director"`
+	Producer     string              `json:"producer"`
+	ReleaseDate  string              `json:"release_date"`
+	Characters   []people.People     `json:"characters"`
+	Planets      []planet.Planet     `json:"planets"`
+	Starships    []starship.Starship `json:"starships"`
+	Vehicles     []vehicle.Vehicle   `json:"vehicles"`
+	Species      []species.Species   `json:"species"`
+	Created      string              `json:"_created"`
+	Edited       string              `json:"_edited"`
+	URL          string              `json:"url"`
+}
+
+// joinTables lists tables linking a film to other resources
+var joinTables = []string{
+	"films_people",
+	"films_planets",
+	"films_starships",
+	"films_vehicles",
+	"films_species",
+}
+
+// AllFilms fetches a page of films from storage, along with their total count
+func (r Repository) AllFilms(page d.Page) ([]Film, int, error) {
+	films := make([]Film, 0)
+
+	stmt, err := r.db.Prepare(`SELECT id, title, episode_id, opening_crawl, director, producer, release_date, created, edited, url
+        FROM films
+        ORDER BY created
+        LIMIT ? OFFSET ?`, page.Limit, page.Offset())
+	if err != nil {
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, 0, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		f, err := buildFilm(stmt)
+		if err != nil {
+			return nil, 0, err
+		}
+		films = append(films, f)
+	}
+	if err := r.embed(films); err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM films`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return films, count, nil
+}
+
+// FilmByID fetches one film from storage
+func (r Repository) FilmByID(id int) (*Film, error) {
+	stmt, err := r.db.Prepare(`SELECT id, title, episode_id, opening_crawl, director, producer, release_date, created, edited, url
+        FROM films
+        WHERE id = ?`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return nil, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return nil, failure.NotFound("Film", id)
+	}
+
+	f, err := buildFilm(stmt)
+	if err != nil {
+		return nil, err
+	}
+	fs := []Film{f}
+	if err := r.embed(fs); err != nil {
+		return nil, err
+	}
+	return &fs[0], nil
+}
+
+// PostFilm set one film into storage. Its relations are read-only
+func (r Repository) PostFilm(f Film) (int, error) {
+	if err := checkReadOnly(f); err != nil {
+		return 0, err
+	}
+	date := time.Now().Format(time.RFC3339)
+	return r.db.Insert("films",
+		[]string{"title", "episode_id", "opening_crawl", "director", "producer", "release_date", "created", "edited", "url"},
+		f.Title,
+		f.EpisodeID,
+		f.OpeningCrawl,
+		f.Director,
+		f.Producer,
+		f.ReleaseDate,
+		date,
+		date,
+		f.URL,
+	)
+}
+
+// PutFilm updates a film into storage. Its relations are read-only
+func (r Repository) PutFilm(id int, f Film) error {
+	if err := checkReadOnly(f); err != nil {
+		return err
+	}
+
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.FilmByID(id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `UPDATE films
+        SET title = ?, episode_id = ?, opening_crawl = ?, director = ?, producer = ?, release_date = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			f.Title,
+			f.EpisodeID,
+			f.OpeningCrawl,
+			f.Director,
+			f.Producer,
+			f.ReleaseDate,
+			time.Now().Format(time.RFC3339),
+			f.URL,
+			id,
+		)
+	})
+}
+
+// checkReadOnly ensures a film to store links no resource, relations not being written through a film
+func checkReadOnly(f Film) error {
+	fields := make(map[string]string)
+	for name, count := range map[string]int{
+		"characters": len(f.Characters),
+		"planets":    len(f.Planets),
+		"starships":  len(f.Starships),
+		"vehicles":   len(f.Vehicles),
+		"species":    len(f.Species),
+	} {
+		if count > 0 {
+			fields[name] = "Read-only field, must be empty"
+		}
+	}
+	if len(fields) > 0 {
+		return failure.Invalid(fields)
+	}
+
+	return nil
+}
+
+// DeleteFilm unsets a film, and its links to other resources, from storage
+func (r Repository) DeleteFilm(id int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.FilmByID(id); err != nil {
+			return err
+		}
+
+		for _, table := range joinTables {
+			if err := d.Exec(t.db, `DELETE FROM `+table+` WHERE films = ?`, id); err != nil {
+				return err
+			}
+		}
+
+		return d.Exec(t.db, `DELETE FROM films WHERE id = ?`, id)
+	})
+}
+
+// embed attaches every resource linked to films, each kind of resource being fetched in one go for all films
+func (r Repository) embed(fs []Film) error {
+	ids := make([]int, 0, len(fs))
+	for _, f := range fs {
+		ids = append(ids, f.ID)
+	}
+
+	characters, err := people.NewRepo(r.db).PeoplesByFilmIDs(ids)
+	if err != nil {
+		return err
+	}
+	planets, err := planet.NewRepo(r.db).PlanetsByFilmIDs(ids)
+	if err != nil {
+		return err
+	}
+	starships, err := starship.NewRepo(r.db).StarshipsByFilmIDs(ids)
+	if err != nil {
+		return err
+	}
+	vehicles, err := vehicle.NewRepo(r.db).VehiclesByFilmIDs(ids)
+	if err != nil {
+		return err
+	}
+	ss, err := species.NewRepo(r.db).SpeciesByFilmIDs(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range fs {
+		f := &fs[i]
+		if f.Characters = characters[f.ID]; f.Characters == nil {
+			f.Characters = make([]people.People, 0)
+		}
+		if f.Planets = planets[f.ID]; f.Planets == nil {
+			f.Planets = make([]planet.Planet, 0)
+		}
+		if f.Starships = starships[f.ID]; f.Starships == nil {
+			f.Starships = make([]starship.Starship, 0)
+		}
+		if f.Vehicles = vehicles[f.ID]; f.Vehicles == nil {
+			f.Vehicles = make([]vehicle.Vehicle, 0)
+		}
+		if f.Species = ss[f.ID]; f.Species == nil {
+			f.Species = make([]species.Species, 0)
+		}
+	}
+
+	return nil
+}
+
+func buildFilm(s d.Stmt) (Film, error) {
+	var id int
+	var title string
+	var episodeID int
+	var openingCrawl string
+	var director string
+	var producer string
+	var releaseDate string
+	var created string
+	var edited string
+	var url string
+
+	err := s.Scan(&id, &title, &episodeID, &openingCrawl, &director, &producer, &releaseDate, &created, &edited, &url)
+	if err != nil {
+		return Film{}, failure.Unavailable("Scan gave error", err)
+	}
+
+	return Film{
+		ID:           id,
+		Title:        title,
+		EpisodeID:    episodeID,
+		OpeningCrawl: openingCrawl,
+		Director:     director,
+		Producer:     producer,
+		ReleaseDate:  releaseDate,
+		Created:      created,
+		Edited:       edited,
+		URL:          url,
+	}, nil
+}
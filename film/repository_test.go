@@ -0,0 +1,169 @@
+package film
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/people"
+	"github.com/prytoegrian/swapi/planet"
+)
+
+type DataDouble struct{}
+
+type StmtDouble struct{}
+
+func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	return StmtDouble{}, nil
+}
+
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
+func (s StmtDouble) Close() error {
+	return nil
+}
+
+var step int
+
+func (s StmtDouble) Step() (bool, error) {
+	step++
+	return (step <= 2), nil
+}
+
+var exec error
+
+func (s StmtDouble) Exec(...interface{}) error {
+	return exec
+}
+
+func (s StmtDouble) Scan(dst ...interface{}) error {
+	return nil
+}
+
+var repo = NewRepo(DataDouble{})
+
+func TestAllFilmsOK(t *testing.T) {
+	step = 1
+	fs, _, err := repo.AllFilms(database.NewPage(1, 10))
+	if err != nil || len(fs) != 1 {
+		t.Error("No film")
+	}
+}
+
+func TestAllFilmsKO(t *testing.T) {
+	step = 3
+	fs, _, err := repo.AllFilms(database.NewPage(1, 10))
+	if err != nil || len(fs) != 0 {
+		t.Error("There's film")
+	}
+}
+
+func TestFilmByIDOK(t *testing.T) {
+	step = 1
+	if _, err := repo.FilmByID(4); err != nil {
+		t.Error("There's no film with this id")
+	}
+}
+
+func TestFilmByIDKO(t *testing.T) {
+	step = 2
+	if _, err := repo.FilmByID(9); err == nil {
+		t.Error("There's film with this id")
+	}
+}
+
+func TestPostFilmFail(t *testing.T) {
+	step = 0
+	exec = errors.New("")
+	f := Film{
+		Title: "The Force Awakens",
+	}
+	if _, err := repo.PostFilm(f); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPostFilmOK(t *testing.T) {
+	step = 0
+	exec = nil
+	f := Film{
+		Title: "The Force Awakens",
+	}
+	if _, err := repo.PostFilm(f); err != nil {
+		t.Error("Post failed")
+	}
+}
+
+func TestPostFilmRelationsReadOnly(t *testing.T) {
+	f := Film{
+		Title:      "The Force Awakens",
+		Characters: []people.People{{ID: 1}},
+		Planets:    []planet.Planet{{ID: 1}},
+	}
+	var e *failure.Error
+	if _, err := repo.PostFilm(f); !errors.As(err, &e) || len(e.Fields) != 2 {
+		t.Error("Relations silently dropped")
+	}
+}
+
+func TestPutFilmNoFilm(t *testing.T) {
+	step = 2
+	if err := repo.PutFilm(8, Film{}); err == nil {
+		t.Error("Found film with this id")
+	}
+}
+
+func TestPutFilmFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.PutFilm(4, Film{}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPutFilmOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.PutFilm(4, Film{}); err != nil {
+		t.Error("Put failed")
+	}
+}
+
+func TestDeleteFilmNoFilm(t *testing.T) {
+	step = 2
+	if err := repo.DeleteFilm(8); err == nil {
+		t.Error("Found film with this id")
+	}
+}
+
+func TestDeleteFilmFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.DeleteFilm(4); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestDeleteFilmOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.DeleteFilm(4); err != nil {
+		t.Error("Delete failed")
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxBody is the greatest size, in bytes, of a request body
+const maxBody = 1 << 20
+
+const (
+	// jsonType is the media type of a whole resource
+	jsonType = "application/json"
+	// mergePatchType is the media type of a JSON Merge Patch (RFC 7396)
+	mergePatchType = "application/merge-patch+json"
+	// jsonPatchType is the media type of a JSON Patch (RFC 6902)
+	jsonPatchType = "application/json-patch+json"
+)
+
+// unsupported refuses a request whose body is not declared with one of the accepted media types
+func unsupported(r *http.Request, accepted ...string) Response {
+	t := mediaType(r)
+	for _, a := range accepted {
+		if t == a {
+			return nil
+		}
+	}
+
+	return Output{
+		Code:    http.StatusUnsupportedMediaType,
+		Status:  "Fail",
+		Message: "Content-Type must be " + strings.Join(accepted, " or "),
+	}
+}
+
+// mediaType reads the media type of a request body, without its parameters
+func mediaType(r *http.Request) string {
+	t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	return t
+}
+
+// decoder reads a request body strictly : unknown fields are refused, as is a body larger than maxBody
+func decoder(w http.ResponseWriter, r *http.Request) *json.Decoder {
+	d := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	d.DisallowUnknownFields()
+
+	return d
+}
+
+// decode fills v with the single JSON value of a body, explaining precisely what is wrong otherwise
+func decode(d *json.Decoder, v interface{}) Response {
+	if err := d.Decode(v); err != nil {
+		return malformed(err)
+	}
+
+	offset := d.InputOffset()
+	if _, err := d.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return malformed(err)
+		}
+
+		return badRequestBecause("Unexpected data after JSON value at byte offset " + strconv.FormatInt(offset, 10))
+	}
+
+	return nil
+}
+
+// malformed explains why a body could not be decoded
+func malformed(err error) Response {
+	var syntax *json.SyntaxError
+	var wrongType *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return Output{
+			Code:    http.StatusRequestEntityTooLarge,
+			Status:  "Fail",
+			Message: "Body larger than " + strconv.Itoa(maxBody) + " bytes",
+		}
+	case errors.Is(err, io.EOF):
+		return badRequestBecause("Empty body")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequestBecause("Truncated JSON")
+	case errors.As(err, &syntax):
+		return badRequestBecause("Malformed JSON at byte offset " + strconv.FormatInt(syntax.Offset, 10) + " : " + syntax.Error())
+	case errors.As(err, &wrongType):
+		return badRequestBecause("Field " + wrongType.Field + " must be " + wrongType.Type.String() + ", not " + wrongType.Value + ", at byte offset " + strconv.FormatInt(wrongType.Offset, 10))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return badRequestBecause("Unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return badRequest()
+	}
+}
+
+func badRequestBecause(message string) Output {
+	o := badRequest()
+	o.Message = message
+
+	return o
+}
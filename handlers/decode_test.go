@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type target struct {
+	Name string `json:"name"`
+	Mass int    `json:"mass"`
+}
+
+// decodeBody decodes a body as a handler does
+func decodeBody(body string) Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/peoples", strings.NewReader(body))
+	var v target
+
+	return decode(decoder(w, r), &v)
+}
+
+// message tells the status and message of a response
+func message(o Response) (int, string) {
+	out, ok := o.(Output)
+	if !ok {
+		return 0, ""
+	}
+
+	return out.Code, out.Message
+}
+
+func TestUnsupported(t *testing.T) {
+	r := httptest.NewRequest("POST", "/peoples", nil)
+	r.Header.Set("Content-Type", "text/plain")
+	if code, msg := message(unsupported(r, jsonType)); code != http.StatusUnsupportedMediaType || msg != "Content-Type must be application/json" {
+		t.Error("Wrong media type accepted : " + msg)
+	}
+}
+
+func TestUnsupportedParameters(t *testing.T) {
+	r := httptest.NewRequest("PATCH", "/peoples/1", nil)
+	r.Header.Set("Content-Type", "application/json-patch+json; charset=utf-8")
+	if o := unsupported(r, mergePatchType, jsonPatchType); o != nil {
+		t.Error("Media type with parameters refused")
+	}
+}
+
+func TestDecodeOK(t *testing.T) {
+	if o := decodeBody(`{"name": "Luke", "mass": 77}`); o != nil {
+		t.Error("Valid body refused")
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	body := `{"name": "` + strings.Repeat("a", maxBody) + `"}`
+	if code, msg := message(decodeBody(body)); code != http.StatusRequestEntityTooLarge {
+		t.Error("Large body accepted : " + msg)
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	if _, msg := message(decodeBody("")); msg != "Empty body" {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	if _, msg := message(decodeBody(`{"name": "Lu`)); msg != "Truncated JSON" {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestDecodeSyntax(t *testing.T) {
+	if code, msg := message(decodeBody(`{"name" "Luke"}`)); code != http.StatusBadRequest || !strings.HasPrefix(msg, "Malformed JSON at byte offset 9 : ") {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestDecodeWrongType(t *testing.T) {
+	if _, msg := message(decodeBody(`{"mass": "heavy"}`)); msg != "Field mass must be int, not string, at byte offset 16" {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestDecodeUnknownField(t *testing.T) {
+	if _, msg := message(decodeBody(`{"weight": 77}`)); msg != `Unknown field "weight"` {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestDecodeTrailingData(t *testing.T) {
+	body := `{"name": "Luke"}`
+	if _, msg := message(decodeBody(body + ` {"name": "Leia"}`)); msg != "Unexpected data after JSON value at byte offset "+strconv.Itoa(len(body)) {
+		t.Error("Wrong message : " + msg)
+	}
+}
+
+func TestMalformedNoCause(t *testing.T) {
+	if o, ok := malformed(errors.New("json: odd")).(Output); !ok || o.Code != http.StatusBadRequest || o.Cause() != nil {
+		t.Error("Client mistake taken for a failure")
+	}
+}
@@ -2,194 +2,583 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
 	"github.com/prytoegrian/swapi/people"
 )
 
-// NewHandler initialise a new handler
-func NewHandler(r people.Repository) Handler {
+// NewHandler initialise a new handler, logging into l
+func NewHandler(r people.Repository, l *slog.Logger) Handler {
 	return Handler{
-		r: r,
+		r:   r,
+		log: l,
 	}
 }
 
 // Handler contains all routes descriptions
 type Handler struct {
-	r people.Repository
+	r   people.Repository
+	log *slog.Logger
 }
 
 // AllPeoples work on all peoples.
 func (h Handler) AllPeoples(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	var m []byte
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
 
 	switch r.Method {
 	case "GET":
-		filled := filledOK(h.r.AllPeoples())
-		m, _ = json.MarshalIndent(filled, "", " ")
+		o = h.allPeoples(r.URL)
 	case "POST":
-		d := json.NewDecoder(r.Body)
-		m = h.postPeople(d)
+		if o = unsupported(r, jsonType); o == nil {
+			o = h.postPeople(decoder(w, r))
+		}
 	case "OPTIONS":
 		fallthrough
 	default:
 		supported := "GET, POST, OPTIONS"
 		w.Header().Set("Allow", supported)
-		m, _ = json.MarshalIndent(notAllowed(supported), "", " ")
+		o = allowed(r.Method, supported)
 	}
 
-	w.Write(m)
+	write(w, l, o)
 }
 
-func (h Handler) postPeople(d *json.Decoder) []byte {
-	badRequest := badRequest()
-	var o Output
-	var p people.People
-	err := d.Decode(&p)
+func (h Handler) allPeoples(u *url.URL) Response {
+	var o Response
+	p, err := page(u)
+	f := filter(u)
+	s := people.NewSort(u.Query().Get("sort"))
 	if err != nil {
-		log.Print(err)
+		o = fail(err)
+	} else if err := f.Validate(); err != nil {
+		o = fail(err)
+	} else if err := s.Validate(); err != nil {
+		o = fail(err)
+	} else if v, err := view(u); err != nil {
+		o = fail(err)
+	} else if peoples, count, err := h.r.Viewing(v).AllPeoples(p, f, s); err != nil {
+		o = fail(err)
+	} else {
+		o = filledPage(peoples, count, p, u)
+	}
+
+	return o
+}
 
-		o = badRequest
+func (h Handler) postPeople(d *json.Decoder) Response {
+	var o Response
+	var p people.People
+	if fault := decode(d, &p); fault != nil {
+		o = fault
+	} else if err := p.Validate(); err != nil {
+		o = fail(err)
 	} else {
-		if id := h.r.PostPeople(p); id == 0 {
-			o = badRequest
+		if id, err := h.r.PostPeople(p); err != nil {
+			o = fail(err)
+		} else if people, err := h.r.PeopleByID(id); err != nil {
+			o = fail(err)
 		} else {
-			o = voidOK()
+			o = created("/peoples/"+strconv.Itoa(id), people)
 		}
 	}
-	m, _ := json.MarshalIndent(o, "", " ")
 
-	return m
+	return o
 }
 
 // OnePeople work on one people.
 func (h Handler) OnePeople(w http.ResponseWriter, r *http.Request) {
 	qs := mux.Vars(r)
 	id, _ := strconv.Atoi(qs["id"])
-	w.Header().Set("Content-Type", "application/json")
-	var m []byte
+	h.r = h.r.IfMatch(entityTags(r.Header.Get("If-Match")))
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
 
 	switch r.Method {
 	case "GET":
-		m = h.getPeople(id)
+		if v, err := view(r.URL); err != nil {
+			o = fail(err)
+		} else {
+			o = h.getPeople(id, v, entityTags(r.Header.Get("If-None-Match")))
+		}
 	case "PUT":
-		d := json.NewDecoder(r.Body)
-		m = h.putPeople(id, d)
+		if o = unsupported(r, jsonType); o == nil {
+			o = h.putPeople(id, decoder(w, r))
+		}
+	case "PATCH":
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		if o = unsupported(r, mergePatchType, jsonPatchType); o == nil {
+			o = h.patchPeople(id, mediaType(r), decoder(w, r))
+		}
 	case "DELETE":
-		m = h.deletePeople(id)
+		o = h.deletePeople(id)
 	case "OPTIONS":
 		fallthrough
 	default:
-		supported := "GET, PUT, DELETE, OPTIONS"
+		supported := "GET, PUT, PATCH, DELETE, OPTIONS"
 		w.Header().Set("Allow", supported)
-		m, _ = json.MarshalIndent(notAllowed(supported), "", " ")
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		o = allowed(r.Method, supported)
 	}
-	w.Write(m)
+	write(w, l, o)
 }
 
-func (h Handler) getPeople(id int) []byte {
-	var o interface{}
-	people, err := h.r.PeopleByID(id)
+// getPeople gives a people, as the view says, along with its entity tag, or only the tag if the client already knows it
+func (h Handler) getPeople(id int, v people.View, noneMatch []string) Response {
+	var o Response
+	people, err := h.r.Viewing(v).PeopleByID(id)
 	if err != nil {
-		o = notFound(id)
+		o = fail(err)
+	} else if etag := people.ETag(); weaklyMatches(etag, noneMatch) {
+		o = notModified(etag)
 	} else {
-		o = filledOK(people)
+		filled := filledOK(people)
+		filled.etag = etag
+		o = filled
 	}
-	m, _ := json.MarshalIndent(o, "", " ")
 
-	return m
+	return o
 }
 
-func (h Handler) putPeople(id int, d *json.Decoder) []byte {
-	badRequest := badRequest()
-	var o interface{}
+func (h Handler) putPeople(id int, d *json.Decoder) Response {
+	var o Response
 	var p people.People
-	err := d.Decode(&p)
-	if err != nil {
-		o = badRequest
+	if fault := decode(d, &p); fault != nil {
+		o = fault
+	} else if err := p.Validate(); err != nil {
+		o = fail(err)
 	} else {
 		if err := h.r.PutPeople(id, p); err != nil {
-			o = badRequest
+			o = fail(err)
 		} else {
-			o = voidOK()
+			o = h.written(id)
 		}
 	}
 
-	m, _ := json.MarshalIndent(o, "", " ")
+	return o
+}
 
-	return m
+// patchPeople updates some fields of a people, through a merge patch or a JSON Patch
+func (h Handler) patchPeople(id int, t string, d *json.Decoder) Response {
+	var o Response
+	if t == jsonPatchType {
+		var ops people.Operations
+		if fault := decode(d, &ops); fault != nil {
+			o = fault
+		} else if err := h.r.PatchPeopleOperations(id, ops); err != nil {
+			o = fail(err)
+		} else {
+			o = h.written(id)
+		}
+	} else {
+		var p people.Patch
+		if fault := decode(d, &p); fault != nil {
+			o = fault
+		} else if err := h.r.PatchPeople(id, p); err != nil {
+			o = fail(err)
+		} else {
+			o = h.written(id)
+		}
+	}
+
+	return o
 }
 
-func (h Handler) deletePeople(id int) []byte {
-	var j interface{}
+func (h Handler) deletePeople(id int) Response {
+	var j Response
 	if err := h.r.DeletePeople(id); err != nil {
-		j = notFound(id)
+		j = fail(err)
 	} else {
 		j = voidOK()
 	}
 
-	m, _ := json.MarshalIndent(j, "", " ")
+	return j
+}
 
-	return m
+// PeopleVehicle work on the assignment of a vehicle to a people.
+func (h Handler) PeopleVehicle(w http.ResponseWriter, r *http.Request) {
+	qs := mux.Vars(r)
+	id, _ := strconv.Atoi(qs["id"])
+	vehicleID, _ := strconv.Atoi(qs["vid"])
+	h.r = h.r.IfMatch(entityTags(r.Header.Get("If-Match")))
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
+
+	switch r.Method {
+	case "PUT":
+		o = h.assignment(id, vehicleID, h.r.PutPeopleVehicle)
+	case "DELETE":
+		o = h.assignment(id, vehicleID, h.r.DeletePeopleVehicle)
+	case "OPTIONS":
+		fallthrough
+	default:
+		supported := "PUT, DELETE, OPTIONS"
+		w.Header().Set("Allow", supported)
+		o = allowed(r.Method, supported)
+	}
+	write(w, l, o)
+}
+
+// PeopleStarship work on the assignment of a starship to a people.
+func (h Handler) PeopleStarship(w http.ResponseWriter, r *http.Request) {
+	qs := mux.Vars(r)
+	id, _ := strconv.Atoi(qs["id"])
+	starshipID, _ := strconv.Atoi(qs["sid"])
+	h.r = h.r.IfMatch(entityTags(r.Header.Get("If-Match")))
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
+
+	switch r.Method {
+	case "PUT":
+		o = h.assignment(id, starshipID, h.r.PutPeopleStarship)
+	case "DELETE":
+		o = h.assignment(id, starshipID, h.r.DeletePeopleStarship)
+	case "OPTIONS":
+		fallthrough
+	default:
+		supported := "PUT, DELETE, OPTIONS"
+		w.Header().Set("Allow", supported)
+		o = allowed(r.Method, supported)
+	}
+	write(w, l, o)
+}
+
+func (h Handler) assignment(id int, resourceID int, apply func(int, int) error) Response {
+	var j Response
+	if err := apply(id, resourceID); err != nil {
+		j = fail(err)
+	} else {
+		j = h.written(id)
+	}
+
+	return j
+}
+
+// written answers a successful write of a people with its new entity tag, so that the client may write it again
+// without fetching it first
+func (h Handler) written(id int) Response {
+	var o Response
+	if p, err := h.r.PeopleByID(id); err != nil {
+		o = fail(err)
+	} else {
+		ok := voidOK()
+		ok.etag = p.ETag()
+		o = ok
+	}
+
+	return o
 }
 
 func voidOK() Output {
 	return Output{
-		Code:    200,
+		Code:    http.StatusOK,
 		Status:  "OK",
 		Message: "",
 	}
 }
 
-func filledOK(d interface{}) interface{} {
+func filledOK(d interface{}) FilledOutput {
 	ok := voidOK()
-	filled := struct {
-		Code    int         `json:"code"`
-		Status  string      `json:"status"`
-		Message string      `json:"message"`
-		Data    interface{} `json:"data"`
-	}{
+	return FilledOutput{
 		Code:    ok.Code,
 		Status:  ok.Status,
 		Message: ok.Message,
 		Data:    d,
 	}
+}
+
+func created(location string, d interface{}) FilledOutput {
+	filled := filledOK(d)
+	filled.Code = http.StatusCreated
+	filled.location = location
 
 	return filled
 }
 
-func notFound(id int) Output {
-	return Output{
-		Code:    404,
-		Status:  "Fail",
-		Message: "People #" + strconv.Itoa(id) + " not found",
+func notModified(etag string) FilledOutput {
+	return FilledOutput{
+		Code:   http.StatusNotModified,
+		Status: "OK",
+		etag:   etag,
+	}
+}
+
+// entityTags reads the list of entity tags of a conditional header
+func entityTags(header string) []string {
+	var etags []string
+	for _, t := range strings.Split(header, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			etags = append(etags, t)
+		}
+	}
+
+	return etags
+}
+
+// weaklyMatches tells if an entity tag is among others, weak ones included, "*" matching any
+func weaklyMatches(etag string, etags []string) bool {
+	for _, t := range etags {
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// page reads the window asked by the query string
+func page(u *url.URL) (database.Page, error) {
+	q := u.Query()
+	var number, limit int
+	var err error
+	if v := q.Get("page"); v != "" {
+		if number, err = strconv.Atoi(v); err != nil || number < 1 {
+			return database.Page{}, failure.Validation("Malformed page")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return database.Page{}, failure.Validation("Malformed limit")
+		}
+	}
+
+	return database.NewPage(number, limit), nil
+}
+
+// filter reads the people filters of the query string, leaving pagination, sort and view aside
+func filter(u *url.URL) people.Filter {
+	f := make(people.Filter)
+	for name, values := range u.Query() {
+		if name == "page" || name == "limit" || name == "sort" || name == "expand" || name == "fields" {
+			continue
+		}
+		f[name] = values[0]
+	}
+
+	return f
+}
+
+// view reads the relations to embed and the fields to show asked by the query string
+func view(u *url.URL) (people.View, error) {
+	q := u.Query()
+	v := people.View{
+		Expand: people.NewExpand(q["expand"]),
+		Fields: people.NewFields(q.Get("fields")),
+	}
+
+	return v, v.Validate()
+}
+
+// pageURL builds the link to another page of the same list
+func pageURL(u *url.URL, number int) *string {
+	q := u.Query()
+	q.Set("page", strconv.Itoa(number))
+	link := u.Path + "?" + q.Encode()
+
+	return &link
+}
+
+func filledPage(d interface{}, count int, p database.Page, u *url.URL) PageOutput {
+	ok := voidOK()
+	var next, previous *string
+	if p.HasNext(count) {
+		next = pageURL(u, p.Number+1)
+	}
+	if p.HasPrevious() {
+		previous = pageURL(u, p.Number-1)
+	}
+
+	return PageOutput{
+		Code:     ok.Code,
+		Status:   ok.Status,
+		Message:  ok.Message,
+		Count:    count,
+		Next:     next,
+		Previous: previous,
+		Data:     d,
 	}
 }
 
 func badRequest() Output {
 	return Output{
-		Code:    400,
+		Code:    http.StatusBadRequest,
 		Status:  "Fail",
 		Message: "Bad request",
 	}
 }
 
+// fail translates a failure into a jsend fail, or error for storage failures.
+// Wrong fields of a validation failure are detailed in data, keyed by field name
+func fail(err error) Response {
+	var f *failure.Error
+	switch {
+	case errors.Is(err, failure.ErrNotFound):
+		return failed(http.StatusNotFound, err)
+	case errors.Is(err, failure.ErrConflict):
+		return failed(http.StatusConflict, err)
+	case errors.Is(err, failure.ErrPrecondition):
+		return failed(http.StatusPreconditionFailed, err)
+	case errors.Is(err, failure.ErrValidation) && errors.As(err, &f) && len(f.Fields) > 0:
+		return FilledOutput{
+			Code:    http.StatusBadRequest,
+			Status:  "Fail",
+			Message: err.Error(),
+			Data:    f.Fields,
+		}
+	case errors.Is(err, failure.ErrValidation):
+		return failed(http.StatusBadRequest, err)
+	default:
+		return Output{
+			Code:    http.StatusInternalServerError,
+			Status:  "Error",
+			Message: failure.ErrUnavailable.Error(),
+			cause:   err,
+		}
+	}
+}
+
+func failed(code int, err error) Output {
+	return Output{
+		Code:    code,
+		Status:  "Fail",
+		Message: err.Error(),
+	}
+}
+
+// allowed answers an OPTIONS request, any other method not being supported
+func allowed(method string, s string) Output {
+	if method == "OPTIONS" {
+		return voidOK()
+	}
+
+	return notAllowed(s)
+}
+
 func notAllowed(s string) Output {
 	return Output{
-		Code:    405,
+		Code:    http.StatusMethodNotAllowed,
 		Status:  "Fail",
 		Message: "Supported methods : " + s,
 	}
 }
 
+// write sends a response, its HTTP status matching the jsend code. A 304 has no body.
+// The unexpected failure behind a response, if any, is logged into the logger of the request
+func write(w http.ResponseWriter, l *slog.Logger, o Response) {
+	if c, ok := o.(caused); ok && c.Cause() != nil {
+		l.Error("Request failed", "error", c.Cause())
+	}
+	if l, ok := o.(located); ok && l.Location() != "" {
+		w.Header().Set("Location", l.Location())
+	}
+	if t, ok := o.(tagged); ok && t.ETag() != "" {
+		w.Header().Set("ETag", t.ETag())
+	}
+	if o.StatusCode() == http.StatusNotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(o.StatusCode())
+
+	m, _ := json.MarshalIndent(o, "", " ")
+	w.Write(m)
+}
+
+// Response represents any API output, aware of its HTTP status
+type Response interface {
+	StatusCode() int
+}
+
+// located is a response pointing to a resource
+type located interface {
+	Location() string
+}
+
+// caused is a response due to an unexpected failure
+type caused interface {
+	Cause() error
+}
+
+// tagged is a response carrying the entity tag of the resource it describes
+type tagged interface {
+	ETag() string
+}
+
 // Output represents an API output
 type Output struct {
 	Code    int    `json:"code"`
 	Status  string `json:"status"`
 	Message string `json:"message"`
 	Data    string `json:"data"`
+	cause   error
+	etag    string
+}
+
+// StatusCode is the HTTP status of the output
+func (o Output) StatusCode() int {
+	return o.Code
+}
+
+// Cause is the unexpected failure behind the output, if any
+func (o Output) Cause() error {
+	return o.cause
+}
+
+// ETag is the entity tag of the resource the output is about, if any
+func (o Output) ETag() string {
+	return o.etag
+}
+
+// FilledOutput represents an API output holding data
+type FilledOutput struct {
+	Code     int         `json:"code"`
+	Status   string      `json:"status"`
+	Message  string      `json:"message"`
+	Data     interface{} `json:"data"`
+	location string
+	etag     string
+}
+
+// StatusCode is the HTTP status of the output
+func (o FilledOutput) StatusCode() int {
+	return o.Code
+}
+
+// Location is the URL of the resource the output describes, if any
+func (o FilledOutput) Location() string {
+	return o.location
+}
+
+// ETag is the entity tag of the resource the output describes, if any
+func (o FilledOutput) ETag() string {
+	return o.etag
+}
+
+// PageOutput represents an API output holding a page of a list
+type PageOutput struct {
+	Code     int         `json:"code"`
+	Status   string      `json:"status"`
+	Message  string      `json:"message"`
+	Count    int         `json:"count"`
+	Next     *string     `json:"next"`
+	Previous *string     `json:"previous"`
+	Data     interface{} `json:"data"`
+}
+
+// StatusCode is the HTTP status of the output
+func (o PageOutput) StatusCode() int {
+	return o.Code
 }
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/people"
+)
+
+// DataDouble answers every query with a single row of zero values, every write succeeding
+type DataDouble struct{}
+
+type StmtDouble struct {
+	rows int
+}
+
+func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	return &StmtDouble{rows: 1}, nil
+}
+
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 3, nil
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
+func (s *StmtDouble) Close() error {
+	return nil
+}
+
+func (s *StmtDouble) Step() (bool, error) {
+	s.rows--
+	return s.rows >= 0, nil
+}
+
+func (s *StmtDouble) Exec(...interface{}) error {
+	return nil
+}
+
+func (s *StmtDouble) Scan(dst ...interface{}) error {
+	return nil
+}
+
+// luke is a valid people
+const luke = `{"name": "Luke Skywalker", "gender": "male", "birth_year": "19BBY"}`
+
+// serve sends a request through the people routes
+func serve(method string, target string, body string, header http.Header) *httptest.ResponseRecorder {
+	h := NewHandler(people.NewRepo(DataDouble{}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := mux.NewRouter()
+	r.HandleFunc("/peoples", h.AllPeoples)
+	r.HandleFunc("/peoples/{id:[0-9]+}", h.OnePeople)
+	r.HandleFunc("/peoples/{id:[0-9]+}/vehicles/{vid:[0-9]+}", h.PeopleVehicle)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestPostPeopleCreated(t *testing.T) {
+	w := serve("POST", "/peoples", luke, http.Header{"Content-Type": {jsonType}})
+	if w.Code != http.StatusCreated {
+		t.Fatal("Wrong status : " + w.Result().Status)
+	}
+	if w.Header().Get("Location") != "/peoples/3" {
+		t.Error("Wrong location : " + w.Header().Get("Location"))
+	}
+	var o struct {
+		Code   int             `json:"code"`
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &o); err != nil || o.Code != http.StatusCreated || o.Status != "OK" || len(o.Data) == 0 {
+		t.Error("Wrong body : " + w.Body.String())
+	}
+}
+
+func TestGetPeopleTagged(t *testing.T) {
+	w := serve("GET", "/peoples/1", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Error("Untagged people : " + w.Result().Status)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Error("Wrong content type : " + w.Header().Get("Content-Type"))
+	}
+}
+
+func TestGetPeopleNotModified(t *testing.T) {
+	etag := serve("GET", "/peoples/1", "", nil).Header().Get("ETag")
+
+	w := serve("GET", "/peoples/1", "", http.Header{"If-None-Match": {`W/` + etag}})
+	if w.Code != http.StatusNotModified {
+		t.Fatal("Wrong status : " + w.Result().Status)
+	}
+	if w.Header().Get("ETag") != etag || w.Body.Len() != 0 {
+		t.Error("304 must carry the tag and no body")
+	}
+}
+
+func TestPutPeoplePreconditionFailed(t *testing.T) {
+	w := serve("PUT", "/peoples/1", luke, http.Header{"Content-Type": {jsonType}, "If-Match": {`"stale"`}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
+
+func TestPutPeopleMatching(t *testing.T) {
+	etag := serve("GET", "/peoples/1", "", nil).Header().Get("ETag")
+
+	w := serve("PUT", "/peoples/1", luke, http.Header{"Content-Type": {jsonType}, "If-Match": {etag}})
+	if w.Code != http.StatusOK {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Error("Wrong new tag : " + w.Header().Get("ETag"))
+	}
+}
+
+func TestPatchPeopleTagged(t *testing.T) {
+	w := serve("PATCH", "/peoples/1", luke, http.Header{"Content-Type": {mergePatchType}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Error("Untagged patch : " + w.Result().Status)
+	}
+}
+
+func TestPutPeopleVehiclePreconditionFailed(t *testing.T) {
+	w := serve("PUT", "/peoples/1/vehicles/4", "", http.Header{"If-Match": {`"stale"`}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
+
+func TestDeletePeopleVehicleTagged(t *testing.T) {
+	etag := serve("GET", "/peoples/1", "", nil).Header().Get("ETag")
+
+	w := serve("DELETE", "/peoples/1/vehicles/4", "", http.Header{"If-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Error("Untagged unassignment : " + w.Result().Status)
+	}
+}
+
+func TestOptionsPeople(t *testing.T) {
+	w := serve("OPTIONS", "/peoples/1", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Allow") != "GET, PUT, PATCH, DELETE, OPTIONS" {
+		t.Error("Wrong allowed methods : " + w.Header().Get("Allow"))
+	}
+}
+
+func TestDeleteNotAllowed(t *testing.T) {
+	w := serve("DELETE", "/peoples", "", nil)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
+
+func TestGetPeopleTagIgnoresView(t *testing.T) {
+	etag := serve("GET", "/peoples/1", "", nil).Header().Get("ETag")
+	for _, target := range []string{"/peoples/1?fields=name", "/peoples/1?expand=", "/peoples/1?expand=homeworld&fields=name,vehicles"} {
+		if tag := serve("GET", target, "", nil).Header().Get("ETag"); tag != etag {
+			t.Error("Entity tag of " + target + " depends on the view : " + tag)
+		}
+	}
+}
+
+func TestPutPeopleMatchingView(t *testing.T) {
+	etag := serve("GET", "/peoples/1?fields=name", "", nil).Header().Get("ETag")
+
+	w := serve("PUT", "/peoples/1", luke, http.Header{"Content-Type": {jsonType}, "If-Match": {etag}})
+	if w.Code != http.StatusOK {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// requestIDHeader carries the id of a request, given by the client or generated
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey keys the id of a request in its context
+type requestIDKey struct{}
+
+// recorder remembers the status a handler answers with
+type recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// Logging identifies each request, keeping the X-Request-ID the client gave or generating one, echoed in the response.
+// Handlers attach the id to every line they log for the request, see requestLogger. Once answered, the request is logged
+// into l at debug level : method, path, route template, status and duration.
+// It wraps the whole router rather than being used by it : mux only runs its middlewares on matched routes,
+// unrouted requests would be neither identified nor logged
+func Logging(l *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r.Header.Get(requestIDHeader))
+			w.Header().Set(requestIDHeader, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			requestLogger(l, r).Debug("Request answered",
+				"route", route(next, r),
+				"status", rec.status,
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+// route gives the path template of the route serving r, empty if none matches
+func route(next http.Handler, r *http.Request) string {
+	router, ok := next.(*mux.Router)
+	if !ok {
+		return ""
+	}
+	var m mux.RouteMatch
+	if !router.Match(r, &m) || m.Route == nil {
+		return ""
+	}
+	t, _ := m.Route.GetPathTemplate()
+
+	return t
+}
+
+// requestID keeps the id given by a client if sensible, generating a random one otherwise
+func requestID(given string) string {
+	if given != "" && len(given) <= 128 {
+		sensible := true
+		for _, c := range given {
+			if c < '!' || c > '~' {
+				sensible = false
+				break
+			}
+		}
+		if sensible {
+			return given
+		}
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// requestLogger gives the logger of a request : every line it logs carries the id of the request, if any, its method and path
+func requestLogger(l *slog.Logger, r *http.Request) *slog.Logger {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		l = l.With("request_id", id)
+	}
+
+	return l.With("method", r.Method, "path", r.URL.RequestURI())
+}
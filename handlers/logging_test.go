@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/people"
+)
+
+// TracingDouble is a DataDouble logging each statement prepared into the logger it is given, as a traced database does
+type TracingDouble struct {
+	DataDouble
+	log *slog.Logger
+}
+
+func (d TracingDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	if d.log != nil {
+		d.log.Debug("SQL statement", "sql", sql)
+	}
+
+	return d.DataDouble.Prepare(sql, args...)
+}
+
+func (d TracingDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d TracingDouble) WithLogger(l *slog.Logger) database.Database {
+	d.log = l
+
+	return d
+}
+
+// logged sends a request to target through the logged people routes, giving the response and the lines logged
+func logged(target string, header http.Header) (*httptest.ResponseRecorder, []map[string]interface{}) {
+	var b bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := NewHandler(people.NewRepo(TracingDouble{}), l)
+	r := mux.NewRouter()
+	r.HandleFunc("/peoples/{id:[0-9]+}", h.OnePeople)
+
+	req := httptest.NewRequest("GET", target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	Logging(l)(r).ServeHTTP(w, req)
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var m map[string]interface{}
+		if json.Unmarshal([]byte(line), &m) == nil {
+			lines = append(lines, m)
+		}
+	}
+
+	return w, lines
+}
+
+func TestRequestIDKept(t *testing.T) {
+	if id := requestID("7f9c-ab12"); id != "7f9c-ab12" {
+		t.Error("Client id replaced : " + id)
+	}
+}
+
+func TestRequestIDReplaced(t *testing.T) {
+	for _, given := range []string{"", "with space", "new\nline", strings.Repeat("a", 129)} {
+		if id := requestID(given); id == given || len(id) != 32 {
+			t.Error("Invalid id kept : " + id)
+		}
+	}
+}
+
+func TestLoggingEchoesClientID(t *testing.T) {
+	w, _ := logged("/peoples/1", http.Header{"X-Request-Id": {"7f9c-ab12"}})
+	if w.Header().Get(requestIDHeader) != "7f9c-ab12" {
+		t.Error("Client id not echoed : " + w.Header().Get(requestIDHeader))
+	}
+}
+
+func TestLoggingGeneratesID(t *testing.T) {
+	w, _ := logged("/peoples/1", http.Header{"X-Request-Id": {"not valid"}})
+	if id := w.Header().Get(requestIDHeader); id == "" || id == "not valid" {
+		t.Error("Invalid id echoed : " + id)
+	}
+}
+
+func TestLoggingTagsEveryLine(t *testing.T) {
+	_, lines := logged("/peoples/1", http.Header{"X-Request-Id": {"7f9c-ab12"}})
+	var statements, answered int
+	for _, line := range lines {
+		if line["request_id"] != "7f9c-ab12" {
+			t.Errorf("Line without request id : %v", line)
+		}
+		switch line["msg"] {
+		case "SQL statement":
+			statements++
+		case "Request answered":
+			answered++
+			if line["route"] != "/peoples/{id:[0-9]+}" || line["status"] != float64(http.StatusOK) {
+				t.Errorf("Wrong access line : %v", line)
+			}
+		}
+	}
+	if statements == 0 || answered != 1 {
+		t.Errorf("%d statements and %d access lines logged", statements, answered)
+	}
+}
+
+func TestLoggingUnrouted(t *testing.T) {
+	w, lines := logged("/nope", http.Header{"X-Request-Id": {"7f9c-ab12"}})
+	if w.Code != http.StatusNotFound || w.Header().Get(requestIDHeader) != "7f9c-ab12" {
+		t.Errorf("Unrouted request answered %d with id %q", w.Code, w.Header().Get(requestIDHeader))
+	}
+	if len(lines) != 1 || lines[0]["msg"] != "Request answered" || lines[0]["route"] != "" ||
+		lines[0]["status"] != float64(http.StatusNotFound) || lines[0]["request_id"] != "7f9c-ab12" {
+		t.Errorf("Wrong access lines : %v", lines)
+	}
+}
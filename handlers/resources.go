@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/film"
+	"github.com/prytoegrian/swapi/planet"
+	"github.com/prytoegrian/swapi/species"
+	"github.com/prytoegrian/swapi/starship"
+	"github.com/prytoegrian/swapi/vehicle"
+)
+
+// ResourceHandler contains the routes descriptions of a kind of resources T, such as films, stored in a repository R
+type ResourceHandler[T any, R database.Repository[R]] struct {
+	r   R
+	log *slog.Logger
+	// path is the route of the list of resources, such as /films
+	path   string
+	all    func(R, database.Page) ([]T, int, error)
+	byID   func(R, int) (*T, error)
+	post   func(R, T) (int, error)
+	put    func(R, int, T) error
+	delete func(R, int) error
+}
+
+// NewFilmHandler initialise a new film handler, logging into l
+func NewFilmHandler(r film.Repository, l *slog.Logger) ResourceHandler[film.Film, film.Repository] {
+	return ResourceHandler[film.Film, film.Repository]{
+		r:      r,
+		log:    l,
+		path:   "/films",
+		all:    film.Repository.AllFilms,
+		byID:   film.Repository.FilmByID,
+		post:   film.Repository.PostFilm,
+		put:    film.Repository.PutFilm,
+		delete: film.Repository.DeleteFilm,
+	}
+}
+
+// NewPlanetHandler initialise a new planet handler, logging into l
+func NewPlanetHandler(r planet.Repository, l *slog.Logger) ResourceHandler[planet.Planet, planet.Repository] {
+	return ResourceHandler[planet.Planet, planet.Repository]{
+		r:      r,
+		log:    l,
+		path:   "/planets",
+		all:    planet.Repository.AllPlanets,
+		byID:   planet.Repository.PlanetByID,
+		post:   planet.Repository.PostPlanet,
+		put:    planet.Repository.PutPlanet,
+		delete: planet.Repository.DeletePlanet,
+	}
+}
+
+// NewSpeciesHandler initialise a new species handler, logging into l
+func NewSpeciesHandler(r species.Repository, l *slog.Logger) ResourceHandler[species.Species, species.Repository] {
+	return ResourceHandler[species.Species, species.Repository]{
+		r:      r,
+		log:    l,
+		path:   "/species",
+		all:    species.Repository.AllSpecies,
+		byID:   species.Repository.SpeciesByID,
+		post:   species.Repository.PostSpecies,
+		put:    species.Repository.PutSpecies,
+		delete: species.Repository.DeleteSpecies,
+	}
+}
+
+// NewStarshipHandler initialise a new starship handler, logging into l
+func NewStarshipHandler(r starship.Repository, l *slog.Logger) ResourceHandler[starship.Starship, starship.Repository] {
+	return ResourceHandler[starship.Starship, starship.Repository]{
+		r:      r,
+		log:    l,
+		path:   "/starships",
+		all:    starship.Repository.AllStarships,
+		byID:   starship.Repository.StarshipByID,
+		post:   starship.Repository.PostStarship,
+		put:    starship.Repository.PutStarship,
+		delete: starship.Repository.DeleteStarship,
+	}
+}
+
+// NewVehicleHandler initialise a new vehicle handler, logging into l
+func NewVehicleHandler(r vehicle.Repository, l *slog.Logger) ResourceHandler[vehicle.Vehicle, vehicle.Repository] {
+	return ResourceHandler[vehicle.Vehicle, vehicle.Repository]{
+		r:      r,
+		log:    l,
+		path:   "/vehicles",
+		all:    vehicle.Repository.AllVehicles,
+		byID:   vehicle.Repository.VehicleByID,
+		post:   vehicle.Repository.PostVehicle,
+		put:    vehicle.Repository.PutVehicle,
+		delete: vehicle.Repository.DeleteVehicle,
+	}
+}
+
+// All work on all resources.
+func (h ResourceHandler[T, R]) All(w http.ResponseWriter, r *http.Request) {
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
+
+	switch r.Method {
+	case "GET":
+		o = h.allResources(r.URL)
+	case "POST":
+		if o = unsupported(r, jsonType); o == nil {
+			o = h.postResource(decoder(w, r))
+		}
+	case "OPTIONS":
+		fallthrough
+	default:
+		supported := "GET, POST, OPTIONS"
+		w.Header().Set("Allow", supported)
+		o = allowed(r.Method, supported)
+	}
+
+	write(w, l, o)
+}
+
+func (h ResourceHandler[T, R]) allResources(u *url.URL) Response {
+	var o Response
+	p, err := page(u)
+	if err != nil {
+		o = fail(err)
+	} else {
+		if resources, count, err := h.all(h.r, p); err != nil {
+			o = fail(err)
+		} else {
+			o = filledPage(resources, count, p, u)
+		}
+	}
+
+	return o
+}
+
+func (h ResourceHandler[T, R]) postResource(d *json.Decoder) Response {
+	var o Response
+	var res T
+	if fault := decode(d, &res); fault != nil {
+		o = fault
+	} else {
+		if id, err := h.post(h.r, res); err != nil {
+			o = fail(err)
+		} else if res, err := h.byID(h.r, id); err != nil {
+			o = fail(err)
+		} else {
+			o = created(h.path+"/"+strconv.Itoa(id), res)
+		}
+	}
+
+	return o
+}
+
+// One work on one resource.
+func (h ResourceHandler[T, R]) One(w http.ResponseWriter, r *http.Request) {
+	qs := mux.Vars(r)
+	id, _ := strconv.Atoi(qs["id"])
+	l := requestLogger(h.log, r)
+	h.r = database.WithLogger(h.r, l)
+	var o Response
+
+	switch r.Method {
+	case "GET":
+		o = h.getResource(id)
+	case "PUT":
+		if o = unsupported(r, jsonType); o == nil {
+			o = h.putResource(id, decoder(w, r))
+		}
+	case "DELETE":
+		o = h.deleteResource(id)
+	case "OPTIONS":
+		fallthrough
+	default:
+		supported := "GET, PUT, DELETE, OPTIONS"
+		w.Header().Set("Allow", supported)
+		o = allowed(r.Method, supported)
+	}
+	write(w, l, o)
+}
+
+func (h ResourceHandler[T, R]) getResource(id int) Response {
+	var o Response
+	res, err := h.byID(h.r, id)
+	if err != nil {
+		o = fail(err)
+	} else {
+		o = filledOK(res)
+	}
+
+	return o
+}
+
+func (h ResourceHandler[T, R]) putResource(id int, d *json.Decoder) Response {
+	var o Response
+	var res T
+	if fault := decode(d, &res); fault != nil {
+		o = fault
+	} else {
+		if err := h.put(h.r, id, res); err != nil {
+			o = fail(err)
+		} else {
+			o = voidOK()
+		}
+	}
+
+	return o
+}
+
+func (h ResourceHandler[T, R]) deleteResource(id int) Response {
+	var j Response
+	if err := h.delete(h.r, id); err != nil {
+		j = fail(err)
+	} else {
+		j = voidOK()
+	}
+
+	return j
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/species"
+)
+
+// serveSpecies sends a request through the species routes
+func serveSpecies(method string, target string, body string) *httptest.ResponseRecorder {
+	h := NewSpeciesHandler(species.NewRepo(DataDouble{}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := mux.NewRouter()
+	r.HandleFunc("/species", h.All)
+	r.HandleFunc("/species/{id:[0-9]+}", h.One)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", jsonType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestPostResourceCreated(t *testing.T) {
+	w := serveSpecies("POST", "/species", `{"name": "Ewok"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/species/3" {
+		t.Error("Wrong creation : " + w.Result().Status + " at " + w.Header().Get("Location"))
+	}
+}
+
+func TestGetResourceOK(t *testing.T) {
+	w := serveSpecies("GET", "/species/1", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data"`) {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
+
+func TestPutResourceUnknownField(t *testing.T) {
+	w := serveSpecies("PUT", "/species/1", `{"nom": "Ewok"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Error("Wrong status : " + w.Result().Status)
+	}
+}
+
+func TestOptionsResource(t *testing.T) {
+	w := serveSpecies("OPTIONS", "/species/1", "")
+	if w.Code != http.StatusOK || w.Header().Get("Allow") != "GET, PUT, DELETE, OPTIONS" {
+		t.Error("Wrong allowed methods : " + w.Header().Get("Allow"))
+	}
+}
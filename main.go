@@ -1,34 +1,182 @@
 package main
 
 import (
-	"flag"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/gorilla/mux"
+	"github.com/prytoegrian/swapi/config"
 	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/film"
 	"github.com/prytoegrian/swapi/handlers"
 	"github.com/prytoegrian/swapi/people"
+	"github.com/prytoegrian/swapi/planet"
+	"github.com/prytoegrian/swapi/species"
+	"github.com/prytoegrian/swapi/starship"
+	"github.com/prytoegrian/swapi/vehicle"
 )
 
 func main() {
-	log.Println("Le serveur écoute désormais à http://localhost:8080")
-	log.Println("Pour couper le serveur, tapez simplement Ctrl-C")
-	// flag log each route, operation
-	var debug int
-	flag.IntVar(&debug, "debug", 0, "Enable ou disable full log")
-	flag.Parse()
-
-	db := database.NewDb()
+	c, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	l := newLogger(c)
+	slog.SetDefault(l)
+	policy, err := people.NewDeletePolicy(c.OnDelete)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	c.Database.Trace = c.Debug >= 2
+	db, err := database.NewDb(c.Database)
+	if err != nil {
+		fatal("Cannot open database", err)
+	}
+
 	r := mux.NewRouter()
-	repo := people.NewRepo(db)
-	h := handlers.NewHandler(repo)
+	repo := people.NewRepo(db).WithDeletePolicy(policy)
+	h := handlers.NewHandler(repo, l)
 
 	r.HandleFunc("/peoples", h.AllPeoples)
 	r.HandleFunc("/peoples/{id:[0-9]+}", h.OnePeople)
+	r.HandleFunc("/peoples/{id:[0-9]+}/vehicles/{vid:[0-9]+}", h.PeopleVehicle)
+	r.HandleFunc("/peoples/{id:[0-9]+}/starships/{sid:[0-9]+}", h.PeopleStarship)
+
+	fh := handlers.NewFilmHandler(film.NewRepo(db), l)
+	r.HandleFunc("/films", fh.All)
+	r.HandleFunc("/films/{id:[0-9]+}", fh.One)
+
+	ph := handlers.NewPlanetHandler(planet.NewRepo(db), l)
+	r.HandleFunc("/planets", ph.All)
+	r.HandleFunc("/planets/{id:[0-9]+}", ph.One)
+
+	sh := handlers.NewSpeciesHandler(species.NewRepo(db), l)
+	r.HandleFunc("/species", sh.All)
+	r.HandleFunc("/species/{id:[0-9]+}", sh.One)
+
+	ssh := handlers.NewStarshipHandler(starship.NewRepo(db), l)
+	r.HandleFunc("/starships", ssh.All)
+	r.HandleFunc("/starships/{id:[0-9]+}", ssh.One)
 
-	err := http.ListenAndServe(":8080", r)
+	vh := handlers.NewVehicleHandler(vehicle.NewRepo(db), l)
+	r.HandleFunc("/vehicles", vh.All)
+	r.HandleFunc("/vehicles/{id:[0-9]+}", vh.One)
+
+	srv := &http.Server{
+		Handler:      handlers.Logging(l)(r),
+		ReadTimeout:  c.Server.ReadTimeout,
+		WriteTimeout: c.Server.WriteTimeout,
+		IdleTimeout:  c.Server.IdleTimeout,
+	}
+	ln, err := listen(c.Server)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Cannot listen", err)
+	}
+
+	l.Info("Listening, Ctrl-C to stop", "location", location(c.Server))
+
+	// Serve in the background while waiting for a stop signal : requests in flight are then given
+	// ShutdownTimeout to end before the database is closed. Requests still running then keep it open until exiting
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(srv, ln, c.Server)
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	code := 0
+	drained := true
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			l.Error("Serving failed", "error", err)
+			code = 1
+		}
+	case s := <-stop:
+		l.Info("Stopping, waiting for requests in flight", "signal", s.String(), "timeout", c.Server.ShutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), c.Server.ShutdownTimeout)
+		if err := srv.Shutdown(ctx); err != nil {
+			l.Error("Requests in flight interrupted", "error", err)
+			code = 1
+			drained = false
+		}
+		cancel()
+	}
+
+	if !drained {
+		l.Warn("Database left open, requests still in flight")
+	} else if err := db.Close(); err != nil {
+		l.Error("Cannot close database", "error", err)
+		code = 1
+	}
+	l.Info("Stopped")
+	os.Exit(code)
+}
+
+// newLogger gives the structured logger of the server, in the configured format.
+// Requests and SQL statements are logged at debug level, enabled by -debug
+func newLogger(c config.Config) *slog.Logger {
+	o := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if c.Debug >= 1 {
+		o.Level = slog.LevelDebug
+	}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, o))
 	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, o))
+}
+
+// fatal logs why the server cannot run, then exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// serve answers requests until the server fails or is shut down, giving http.ErrServerClosed then
+func serve(srv *http.Server, l net.Listener, s config.Server) error {
+	if s.TLS() {
+		return srv.ServeTLS(l, s.TLSCert, s.TLSKey)
+	}
+
+	return srv.Serve(l)
+}
+
+// listen opens the Unix socket if any, the TCP address otherwise. A socket left by a previous run is replaced
+func listen(s config.Server) (net.Listener, error) {
+	if s.Socket == "" {
+		return net.Listen("tcp", s.Addr)
+	}
+
+	if fi, err := os.Stat(s.Socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(s.Socket); err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", s.Socket)
+}
+
+// location describes where the server listens
+func location(s config.Server) string {
+	if s.Socket != "" {
+		return "unix:" + s.Socket
+	}
+	scheme := "http"
+	if s.TLS() {
+		scheme = "https"
+	}
+	host := s.Addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+
+	return scheme + "://" + host
 }
@@ -0,0 +1,140 @@
+package people
+
+import (
+	"errors"
+	"strconv"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/starship"
+	"github.com/prytoegrian/swapi/vehicle"
+)
+
+// PutPeopleVehicle assigns a vehicle to a people, as long as it matches the expected entity tags
+func (r Repository) PutPeopleVehicle(id int, vehicleID int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+		if _, err := vehicle.NewRepo(t.db).VehicleByID(vehicleID); err != nil {
+			return err
+		}
+
+		return t.assign("people_vehicles", "vehicles", id, vehicleID)
+	})
+}
+
+// DeletePeopleVehicle unassigns a vehicle from a people, as long as it matches the expected entity tags
+func (r Repository) DeletePeopleVehicle(id int, vehicleID int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+
+		return t.unassign("people_vehicles", "vehicles", "Vehicle", id, vehicleID)
+	})
+}
+
+// PutPeopleStarship assigns a starship to a people, as long as it matches the expected entity tags
+func (r Repository) PutPeopleStarship(id int, starshipID int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+		if _, err := starship.NewRepo(t.db).StarshipByID(starshipID); err != nil {
+			return err
+		}
+
+		return t.assign("people_starships", "starships", id, starshipID)
+	})
+}
+
+// DeletePeopleStarship unassigns a starship from a people, as long as it matches the expected entity tags
+func (r Repository) DeletePeopleStarship(id int, starshipID int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+
+		return t.unassign("people_starships", "starships", "Starship", id, starshipID)
+	})
+}
+
+// checkAssignments ensures every vehicle and starship of a people exists
+func (r Repository) checkAssignments(p People) error {
+	v := vehicle.NewRepo(r.db)
+	for _, pv := range p.Vehicles {
+		if _, err := v.VehicleByID(pv.ID); errors.Is(err, failure.ErrNotFound) {
+			return failure.Invalid(map[string]string{"vehicles": "Unknown vehicle #" + strconv.Itoa(pv.ID)})
+		} else if err != nil {
+			return err
+		}
+	}
+	s := starship.NewRepo(r.db)
+	for _, ps := range p.Starships {
+		if _, err := s.StarshipByID(ps.ID); errors.Is(err, failure.ErrNotFound) {
+			return failure.Invalid(map[string]string{"starships": "Unknown starship #" + strconv.Itoa(ps.ID)})
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceAssignments sets vehicles and starships of a people, as long as they are given
+func (r Repository) replaceAssignments(id int, p People) error {
+	if p.Vehicles != nil {
+		if err := d.Exec(r.db, `DELETE FROM people_vehicles WHERE people = ?`, id); err != nil {
+			return err
+		}
+		for _, v := range p.Vehicles {
+			if err := r.assign("people_vehicles", "vehicles", id, v.ID); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Starships != nil {
+		if err := d.Exec(r.db, `DELETE FROM people_starships WHERE people = ?`, id); err != nil {
+			return err
+		}
+		for _, s := range p.Starships {
+			if err := r.assign("people_starships", "starships", id, s.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// assign links a people to a resource through a join table, once at most
+func (r Repository) assign(table string, column string, id int, resourceID int) error {
+	if err := d.Exec(r.db, `DELETE FROM `+table+` WHERE people = ? AND `+column+` = ?`, id, resourceID); err != nil {
+		return err
+	}
+
+	return d.Exec(r.db, `INSERT INTO `+table+` (people, `+column+`) VALUES (?, ?)`, id, resourceID)
+}
+
+// unassign unlinks a people from a resource through a join table
+func (r Repository) unassign(table string, column string, resource string, id int, resourceID int) error {
+	stmt, err := r.db.Prepare(`SELECT people FROM `+table+` WHERE people = ? AND `+column+` = ?`, id, resourceID)
+	if err != nil {
+		return failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return &failure.Error{
+			Kind:    failure.ErrNotFound,
+			Message: resource + " #" + strconv.Itoa(resourceID) + " not assigned to People #" + strconv.Itoa(id),
+		}
+	}
+
+	return d.Exec(r.db, `DELETE FROM `+table+` WHERE people = ? AND `+column+` = ?`, id, resourceID)
+}
@@ -0,0 +1,126 @@
+package people
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/starship"
+	"github.com/prytoegrian/swapi/vehicle"
+)
+
+// RowDouble answers every query with a single row
+type RowDouble struct {
+	DataDouble
+}
+
+type RowStmtDouble struct {
+	StmtDouble
+	rows int
+}
+
+func (d RowDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	return &RowStmtDouble{rows: 1}, nil
+}
+
+func (d RowDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d RowDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (s *RowStmtDouble) Step() (bool, error) {
+	s.rows--
+	return s.rows >= 0, nil
+}
+
+func TestPutPeopleVehicleNoPeople(t *testing.T) {
+	step = 2
+	if err := repo.PutPeopleVehicle(1, 4); err == nil {
+		t.Error("Found people with this id")
+	}
+}
+
+func TestPutPeopleVehicleNoVehicle(t *testing.T) {
+	step = 1
+	if err := repo.PutPeopleVehicle(1, 4); err == nil {
+		t.Error("Found vehicle with this id")
+	}
+}
+
+func TestDeletePeopleVehicleNoAssignment(t *testing.T) {
+	step = 2
+	if err := repo.DeletePeopleVehicle(1, 4); err == nil {
+		t.Error("Found vehicle assigned to this people")
+	}
+}
+
+func TestDeletePeopleVehicleOK(t *testing.T) {
+	exec = nil
+	if err := NewRepo(RowDouble{}).DeletePeopleVehicle(1, 4); err != nil {
+		t.Error("Unassignment failed")
+	}
+}
+
+func TestPutPeopleVehicleMismatch(t *testing.T) {
+	step = 1
+	if err := repo.IfMatch([]string{`"outdated"`}).PutPeopleVehicle(1, 4); !errors.Is(err, failure.ErrPrecondition) {
+		t.Error("Vehicle assigned to a changed people")
+	}
+}
+
+func TestDeletePeopleStarshipMismatch(t *testing.T) {
+	step = 1
+	if err := repo.IfMatch([]string{`"outdated"`}).DeletePeopleStarship(1, 12); !errors.Is(err, failure.ErrPrecondition) {
+		t.Error("Starship unassigned from a changed people")
+	}
+}
+
+func TestPutPeopleStarshipNoStarship(t *testing.T) {
+	step = 1
+	if err := repo.PutPeopleStarship(1, 12); err == nil {
+		t.Error("Found starship with this id")
+	}
+}
+
+func TestDeletePeopleStarshipFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	defer func() { exec = nil }()
+	if err := repo.DeletePeopleStarship(1, 12); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestCheckAssignmentsUnknownVehicle(t *testing.T) {
+	step = 2
+	p := People{
+		Vehicles: []vehicle.Vehicle{{ID: 4}},
+	}
+	if err := repo.checkAssignments(p); err == nil {
+		t.Error("Found vehicle with this id")
+	}
+}
+
+func TestReplaceAssignmentsNotGiven(t *testing.T) {
+	exec = errors.New("")
+	defer func() { exec = nil }()
+	if err := repo.replaceAssignments(1, People{}); err != nil {
+		t.Error("Assignments were replaced")
+	}
+}
+
+func TestReplaceAssignmentsFail(t *testing.T) {
+	exec = errors.New("")
+	defer func() { exec = nil }()
+	p := People{
+		Starships: []starship.Starship{},
+	}
+	if err := repo.replaceAssignments(1, p); err == nil {
+		t.Error("Fail exec")
+	}
+}
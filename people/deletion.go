@@ -0,0 +1,116 @@
+package people
+
+import (
+	"strconv"
+	"strings"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// DeletePolicy tells what becomes of the links of a deleted people
+type DeletePolicy int
+
+const (
+	// Cascade deletes the links along with the people
+	Cascade DeletePolicy = iota
+	// Restrict refuses to delete a people still linked to other resources
+	Restrict
+)
+
+// NewDeletePolicy reads a policy from its name, "cascade" or "restrict"
+func NewDeletePolicy(name string) (DeletePolicy, error) {
+	switch name {
+	case "cascade":
+		return Cascade, nil
+	case "restrict":
+		return Restrict, nil
+	default:
+		return Cascade, failure.Validation("Unknown delete policy " + name)
+	}
+}
+
+// link is a join table between peoples and another resource
+type link struct {
+	table  string
+	column string
+}
+
+// relations are the join tables of the relations a people may embed, keyed by column
+var relations = []link{
+	{table: "people_vehicles", column: "vehicles"},
+	{table: "people_starships", column: "starships"},
+	{table: "people_species", column: "species"},
+}
+
+// links are all join tables referencing peoples
+var links = append(relations[:len(relations):len(relations)], link{table: "films_people", column: "films"})
+
+// WithDeletePolicy gives a repository applying the policy when deleting peoples
+func (r Repository) WithDeletePolicy(p DeletePolicy) Repository {
+	r.onDelete = p
+
+	return r
+}
+
+// unlink applies the delete policy to the links of a people, before its deletion
+func (r Repository) unlink(id int) error {
+	if r.onDelete == Restrict {
+		return r.restrict(id)
+	}
+
+	for _, l := range links {
+		if err := d.Exec(r.db, `DELETE FROM `+l.table+` WHERE people = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restrict fails with a conflict listing the resources still linked to a people, if any
+func (r Repository) restrict(id int) error {
+	var dependants []string
+	for _, l := range links {
+		ids, err := r.linked(l, id)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			dependants = append(dependants, l.column+" #"+strings.Join(ids, ", #"))
+		}
+	}
+	if len(dependants) == 0 {
+		return nil
+	}
+
+	return failure.Conflict("People #" + strconv.Itoa(id) + " still linked to " + strings.Join(dependants, "; "))
+}
+
+// linked lists the ids of the resources a people is linked to through a join table
+func (r Repository) linked(l link, id int) ([]string, error) {
+	stmt, err := r.db.Prepare(`SELECT `+l.column+` FROM `+l.table+` WHERE people = ? ORDER BY CAST(`+l.column+` AS INTEGER)`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	ids := make([]string, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var resourceID string
+		if err := stmt.Scan(&resourceID); err != nil {
+			return nil, failure.Unavailable("Scan gave error", err)
+		}
+		ids = append(ids, resourceID)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,44 @@
+package people
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+func TestNewDeletePolicyOK(t *testing.T) {
+	if p, err := NewDeletePolicy("restrict"); err != nil || p != Restrict {
+		t.Error("Restrict policy not read")
+	}
+}
+
+func TestNewDeletePolicyUnknown(t *testing.T) {
+	if _, err := NewDeletePolicy("ignore"); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Unknown policy accepted")
+	}
+}
+
+func TestUnlinkCascadeFail(t *testing.T) {
+	exec = errors.New("")
+	defer func() { exec = nil }()
+	if err := repo.unlink(1); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestUnlinkRestrictLinked(t *testing.T) {
+	step = 1
+	if err := repo.WithDeletePolicy(Restrict).unlink(1); !errors.Is(err, failure.ErrConflict) {
+		t.Error("Linked people unlinked")
+	}
+}
+
+func TestUnlinkRestrictFree(t *testing.T) {
+	step = 2
+	exec = errors.New("")
+	defer func() { exec = nil }()
+	if err := repo.WithDeletePolicy(Restrict).unlink(1); err != nil {
+		t.Error("Free people not unlinked")
+	}
+}
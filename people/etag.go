@@ -0,0 +1,76 @@
+package people
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// versioned are the relations whose ids are part of the entity tag : the assignments a people owns
+var versioned = []string{"vehicles", "starships"}
+
+// ETag is the strong entity tag of a people, derived from its own columns and the ids of its assigned vehicles and starships.
+// Linked resources changing on their own, such as its homeworld or a vehicle, leave it unchanged, as does the view
+func (p People) ETag() string {
+	state := struct {
+		ID        int
+		Name      string
+		Height    int
+		Mass      int
+		Hair      string
+		Skin      string
+		Eye       string
+		BirthYear string
+		Gender    string
+		Homeworld int
+		Created   string
+		Edited    string
+		URL       string
+		Vehicles  []int
+		Starships []int
+	}{p.ID, p.Name, p.Height, p.Mass, p.Hair, p.Skin, p.Eye, p.BirthYear, p.Gender, p.Homeworld, p.Created, p.Edited, p.URL,
+		sorted(p.refs["vehicles"]), sorted(p.refs["starships"])}
+	m, _ := json.Marshal(state)
+	sum := sha1.Sum(m)
+
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func sorted(ids []int) []int {
+	s := append(make([]int, 0, len(ids)), ids...)
+	sort.Ints(s)
+
+	return s
+}
+
+// IfMatch gives a repository writing a people only if it matches one of the entity tags, "*" matching any.
+// No tag means no condition
+func (r Repository) IfMatch(etags []string) Repository {
+	r.ifMatch = etags
+
+	return r
+}
+
+// current fetches a people about to be written, ensuring it matches the expected entity tags, if any
+func (r Repository) current(id int) (*People, error) {
+	p, err := r.PeopleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.ifMatch) == 0 {
+		return p, nil
+	}
+
+	etag := p.ETag()
+	for _, t := range r.ifMatch {
+		if t == "*" || t == etag {
+			return p, nil
+		}
+	}
+
+	return nil, failure.Precondition("People #" + strconv.Itoa(id) + " has changed")
+}
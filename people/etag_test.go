@@ -0,0 +1,50 @@
+package people
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/planet"
+	"github.com/prytoegrian/swapi/species"
+)
+
+func TestETagChanges(t *testing.T) {
+	p := People{Name: "Boba Fett", Edited: "2014-12-20T21:17:50.311Z"}
+	etag := p.ETag()
+	if etag != p.ETag() {
+		t.Error("Unstable entity tag")
+	}
+	p.Edited = "2014-12-21T21:17:50.311Z"
+	if etag == p.ETag() {
+		t.Error("Entity tag unchanged")
+	}
+}
+
+func TestETagOwnState(t *testing.T) {
+	p := People{Name: "Boba Fett", Homeworld: 10, refs: map[string][]int{"starships": {21}}}
+	etag := p.ETag()
+	p.Planet = &planet.Planet{ID: 10, Residents: []planet.Resident{{ID: 22}}}
+	p.Species = []species.Species{{ID: 1}}
+	if etag != p.ETag() {
+		t.Error("Entity tag changed with linked resources")
+	}
+	p.refs["starships"] = []int{21, 22}
+	if etag == p.ETag() {
+		t.Error("Entity tag unchanged by assignments")
+	}
+}
+
+func TestIfMatchMismatch(t *testing.T) {
+	step = 1
+	if err := repo.IfMatch([]string{`"outdated"`}).DeletePeople(21); !errors.Is(err, failure.ErrPrecondition) {
+		t.Error("Changed people deleted")
+	}
+}
+
+func TestIfMatchAny(t *testing.T) {
+	step = 1
+	if err := repo.IfMatch([]string{"*"}).DeletePeople(21); err != nil {
+		t.Error("Delete failed")
+	}
+}
@@ -0,0 +1,73 @@
+package people
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// Filter narrows a list of peoples, each key being a filter name
+type Filter map[string]string
+
+// filters maps filter names to their SQL condition
+var filters = map[string]string{
+	"gender":    "gender = ?",
+	"eye":       "eye_color = ?",
+	"homeworld": "homeworld = ?",
+	"search":    `name LIKE ? ESCAPE '\'`,
+}
+
+// Validate ensures every filter is known and well-formed
+func (f Filter) Validate() error {
+	for name, value := range f {
+		if _, ok := filters[name]; !ok {
+			return failure.Validation("Unknown filter " + name)
+		}
+		if name == "homeworld" {
+			if _, err := strconv.Atoi(value); err != nil {
+				return failure.Validation("Malformed filter homeworld")
+			}
+		}
+	}
+
+	return nil
+}
+
+// where translates known filters into a parameterised SQL condition
+func (f Filter) where() (string, []interface{}) {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		if _, ok := filters[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+
+	conditions := make([]string, 0, len(names))
+	args := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		conditions = append(conditions, filters[name])
+		args = append(args, f.arg(name))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (f Filter) arg(name string) interface{} {
+	value := f[name]
+	switch name {
+	case "homeworld":
+		id, _ := strconv.Atoi(value)
+		return id
+	case "search":
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+		return "%" + escaped + "%"
+	default:
+		return value
+	}
+}
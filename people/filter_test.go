@@ -0,0 +1,41 @@
+package people
+
+import "testing"
+
+func TestFilterValidateOK(t *testing.T) {
+	f := Filter{"gender": "male", "homeworld": "1", "search": "sky"}
+	if err := f.Validate(); err != nil {
+		t.Error("Valid filter rejected")
+	}
+}
+
+func TestFilterValidateUnknown(t *testing.T) {
+	f := Filter{"hair": "blond"}
+	if err := f.Validate(); err == nil {
+		t.Error("Unknown filter accepted")
+	}
+}
+
+func TestFilterValidateMalformedHomeworld(t *testing.T) {
+	f := Filter{"homeworld": "tatooine"}
+	if err := f.Validate(); err == nil {
+		t.Error("Malformed homeworld accepted")
+	}
+}
+
+func TestFilterWhereEmpty(t *testing.T) {
+	if w, args := (Filter{}).where(); w != "" || len(args) != 0 {
+		t.Error("Empty filter gave a condition")
+	}
+}
+
+func TestFilterWhere(t *testing.T) {
+	f := Filter{"search": "50%", "eye": "blue"}
+	w, args := f.where()
+	if w != `WHERE eye_color = ? AND name LIKE ? ESCAPE '\'` {
+		t.Error("Wrong condition : " + w)
+	}
+	if len(args) != 2 || args[0] != "blue" || args[1] != `%50\%%` {
+		t.Error("Wrong arguments")
+	}
+}
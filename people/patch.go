@@ -0,0 +1,240 @@
+package people
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// Patch holds the fields of a people to change, keyed by JSON name, as a JSON Merge Patch (RFC 7396) does.
+// A null value resets its field
+type Patch map[string]json.RawMessage
+
+// Operation is an operation of a JSON Patch (RFC 6902), its paths pointing to fields of a people
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Operations is a JSON Patch, applied in order
+type Operations []Operation
+
+// field is a patchable field of a people
+type field struct {
+	// column stores the field in the people table, assignments having none
+	column string
+	// zero is the JSON value of the field once reset
+	zero  string
+	value func(People) interface{}
+}
+
+// patchable maps the JSON name of each writable field of a people to its description
+var patchable = map[string]field{
+	"name":       {column: "name", zero: `""`, value: func(p People) interface{} { return p.Name }},
+	"height":     {column: "height", zero: `0`, value: func(p People) interface{} { return p.Height }},
+	"mass":       {column: "mass", zero: `0`, value: func(p People) interface{} { return p.Mass }},
+	"hair":       {column: "hair_color", zero: `""`, value: func(p People) interface{} { return p.Hair }},
+	"skin":       {column: "skin_color", zero: `""`, value: func(p People) interface{} { return p.Skin }},
+	"eye":        {column: "eye_color", zero: `""`, value: func(p People) interface{} { return p.Eye }},
+	"birth_year": {column: "birth_year", zero: `""`, value: func(p People) interface{} { return p.BirthYear }},
+	"gender":     {column: "gender", zero: `""`, value: func(p People) interface{} { return p.Gender }},
+	"homeworld":  {column: "homeworld", zero: `0`, value: func(p People) interface{} { return p.Homeworld }},
+	"url":        {column: "url", zero: `""`, value: func(p People) interface{} { return p.URL }},
+	"vehicles":   {zero: `[]`},
+	"starships":  {zero: `[]`},
+}
+
+// PatchPeople updates only the given fields of a people, as a JSON Merge Patch. Edited is bumped anyway
+func (r Repository) PatchPeople(id int, p Patch) error {
+	return d.Atomically(r, func(t Repository) error {
+		current, err := t.current(id)
+		if err != nil {
+			return err
+		}
+
+		return t.patch(id, *current, p)
+	})
+}
+
+// PatchPeopleOperations updates only the fields of a people a JSON Patch points to. Edited is bumped anyway
+func (r Repository) PatchPeopleOperations(id int, ops Operations) error {
+	return d.Atomically(r, func(t Repository) error {
+		current, err := t.current(id)
+		if err != nil {
+			return err
+		}
+		p, err := ops.merge(*current)
+		if err != nil {
+			return err
+		}
+
+		return t.patch(id, *current, p)
+	})
+}
+
+// patch applies changes to the current state of a people, then stores the changed columns and assignments
+func (r Repository) patch(id int, p People, changes Patch) error {
+	if err := changes.apply(&p); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if _, ok := changes["homeworld"]; ok {
+		if err := r.checkHomeworld(p); err != nil {
+			return err
+		}
+	}
+	var assignments People
+	if _, ok := changes["vehicles"]; ok {
+		assignments.Vehicles = p.Vehicles
+	}
+	if _, ok := changes["starships"]; ok {
+		assignments.Starships = p.Starships
+	}
+	if err := r.checkAssignments(assignments); err != nil {
+		return err
+	}
+
+	sets := make([]string, 0, len(changes)+1)
+	args := make([]interface{}, 0, len(changes)+2)
+	for _, name := range changes.names() {
+		if f := patchable[name]; f.column != "" {
+			sets = append(sets, f.column+" = ?")
+			args = append(args, f.value(p))
+		}
+	}
+	sets = append(sets, "edited = ?")
+	args = append(args, time.Now().Format(time.RFC3339), id)
+	if err := d.Exec(r.db, `UPDATE people SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...); err != nil {
+		return err
+	}
+
+	return r.replaceAssignments(id, assignments)
+}
+
+// apply sets each changed field of a people, explaining each field which cannot be
+func (p Patch) apply(dst *People) error {
+	fields := make(map[string]string)
+	for _, name := range p.names() {
+		f, ok := patchable[name]
+		if !ok {
+			fields[name] = "Unknown or read-only field"
+			continue
+		}
+		value := p[name]
+		if isNull(value) {
+			value = json.RawMessage(f.zero)
+		}
+		if err := json.Unmarshal([]byte(`{"`+name+`": `+string(value)+`}`), dst); err != nil {
+			fields[name] = "Malformed value"
+		}
+	}
+	if len(fields) > 0 {
+		return failure.Invalid(fields)
+	}
+
+	return nil
+}
+
+func (p Patch) names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// merge plays operations on the JSON document of a people, giving the fields they change as a merge patch
+func (ops Operations) merge(current People) (Patch, error) {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return nil, failure.Validation("Unreadable people")
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, failure.Validation("Unreadable people")
+	}
+
+	changes := make(Patch)
+	for i, op := range ops {
+		invalid := func(message string) error {
+			return failure.Validation("Operation #" + strconv.Itoa(i) + " : " + message)
+		}
+		name, ok := member(op.Path)
+		if !ok {
+			return nil, invalid("Unsupported path " + op.Path)
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return nil, invalid("Missing value")
+			}
+			doc[name], changes[name] = op.Value, op.Value
+		case "remove":
+			doc[name], changes[name] = json.RawMessage("null"), json.RawMessage("null")
+		case "move", "copy":
+			from, ok := member(op.From)
+			if !ok {
+				return nil, invalid("Unsupported from " + op.From)
+			}
+			value, ok := doc[from]
+			if !ok {
+				return nil, invalid("Unknown from " + op.From)
+			}
+			if op.Op == "move" {
+				doc[from], changes[from] = json.RawMessage("null"), json.RawMessage("null")
+			}
+			doc[name], changes[name] = value, value
+		case "test":
+			if op.Value == nil {
+				return nil, invalid("Missing value")
+			}
+			value, ok := doc[name]
+			if !ok {
+				return nil, invalid("Unknown path " + op.Path)
+			}
+			if !sameJSON(value, op.Value) {
+				return nil, failure.Conflict("Operation #" + strconv.Itoa(i) + " : Test failed on " + op.Path)
+			}
+		default:
+			return nil, invalid("Unknown operation " + op.Op)
+		}
+	}
+
+	return changes, nil
+}
+
+// member reads a JSON pointer to a top-level member, like /mass
+func member(pointer string) (string, bool) {
+	if !strings.HasPrefix(pointer, "/") || strings.Contains(pointer[1:], "/") || len(pointer) == 1 {
+		return "", false
+	}
+
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:]), true
+}
+
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+func sameJSON(a json.RawMessage, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
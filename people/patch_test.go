@@ -0,0 +1,77 @@
+package people
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+func TestPatchApply(t *testing.T) {
+	p := People{Name: "Boba Fett", Mass: 78, Hair: "black"}
+	patch := Patch{"mass": json.RawMessage(`79`), "hair": json.RawMessage(`null`)}
+	if err := patch.apply(&p); err != nil || p.Mass != 79 || p.Hair != "" || p.Name != "Boba Fett" {
+		t.Error("Patch not applied")
+	}
+}
+
+func TestPatchApplyUnknown(t *testing.T) {
+	var f *failure.Error
+	patch := Patch{"hair_color": json.RawMessage(`"black"`), "_created": json.RawMessage(`""`), "mass": json.RawMessage(`"heavy"`)}
+	if err := patch.apply(&People{}); !errors.As(err, &f) || len(f.Fields) != 3 {
+		t.Error("Wrong fields patched")
+	}
+}
+
+func TestOperationsMerge(t *testing.T) {
+	ops := Operations{
+		{Op: "test", Path: "/name", Value: json.RawMessage(`"Boba Fett"`)},
+		{Op: "replace", Path: "/mass", Value: json.RawMessage(`79`)},
+		{Op: "remove", Path: "/hair"},
+	}
+	p, err := ops.merge(People{Name: "Boba Fett"})
+	if err != nil || len(p) != 2 || string(p["mass"]) != "79" || !isNull(p["hair"]) {
+		t.Error("Operations not merged")
+	}
+}
+
+func TestOperationsMergeTestFailed(t *testing.T) {
+	ops := Operations{{Op: "test", Path: "/name", Value: json.RawMessage(`"Jango Fett"`)}}
+	if _, err := ops.merge(People{Name: "Boba Fett"}); !errors.Is(err, failure.ErrConflict) {
+		t.Error("Test passed")
+	}
+}
+
+func TestOperationsMergeTestMissingValue(t *testing.T) {
+	ops := Operations{{Op: "test", Path: "/mass"}}
+	if _, err := ops.merge(People{}); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Test without value played")
+	}
+}
+
+func TestOperationsMergeNestedPath(t *testing.T) {
+	ops := Operations{{Op: "remove", Path: "/vehicles/0"}}
+	if _, err := ops.merge(People{}); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Nested path accepted")
+	}
+}
+
+func TestPatchPeopleOK(t *testing.T) {
+	step = 1
+	patch := Patch{
+		"name":       json.RawMessage(`"Boba Fett"`),
+		"gender":     json.RawMessage(`"male"`),
+		"birth_year": json.RawMessage(`"31.5BBY"`),
+	}
+	if err := repo.PatchPeople(1, patch); err != nil {
+		t.Error("Patch failed")
+	}
+}
+
+func TestPatchPeopleInvalid(t *testing.T) {
+	step = 1
+	if err := repo.PatchPeople(1, Patch{"mass": json.RawMessage(`-1`)}); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Invalid people stored")
+	}
+}
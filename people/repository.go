@@ -2,10 +2,13 @@ package people
 
 import (
 	"errors"
-	"log"
+	"strconv"
 	"time"
 
 	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/planet"
+	"github.com/prytoegrian/swapi/species"
 	"github.com/prytoegrian/swapi/starship"
 	"github.com/prytoegrian/swapi/vehicle"
 )
@@ -19,7 +22,22 @@ func NewRepo(db d.Database) Repository {
 
 // Repository is a people repository
 type Repository struct {
-	db d.Database
+	db       d.Database
+	onDelete DeletePolicy
+	ifMatch  []string
+	view     View
+}
+
+// Database is the database the people repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the people repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
 }
 
 // People represents a well-formed people
@@ -34,111 +52,242 @@ type People struct {
 	BirthYear string              `json:"birth_year"`
 	Gender    string              `json:"gender"`
 	Homeworld int                 `json:"homeworld"`
+	Planet    *planet.Planet      `json:"_homeworld"`
 	Films     string              `json:"films"`
-	Species   string              `json:"species"`
+	Species   []species.Species   `json:"species"`
 	Vehicles  []vehicle.Vehicle   `json:"vehicles"`
 	Starships []starship.Starship `json:"starships"`
 	Created   string              `json:"_created"`
 	Edited    string              `json:"_edited"`
 	URL       string              `json:"url"`
+	view      View
+	// refs are the ids of the relations not embedded, keyed by relation
+	refs map[string][]int
 }
 
-// AllPeoples fetches all peoples from storage
-func (r Repository) AllPeoples() []People {
-	peoples := make([]People, 0)
-
+// AllPeoples fetches a sorted page of peoples matching the filter from storage, along with their total count
+func (r Repository) AllPeoples(page d.Page, f Filter, s Sort) ([]People, int, error) {
+	where, args := f.where()
 	stmt, err := r.db.Prepare(`SELECT id, name, height, mass, hair_color, skin_color, eye_color, birth_year, gender, homeworld, created, edited, url
         FROM people
-        ORDER BY created`)
+        `+where+`
+        `+s.orderBy()+`
+        LIMIT ? OFFSET ?`, append(args[:len(args):len(args)], page.Limit, page.Offset())...)
 	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
 	}
 	defer stmt.Close()
-	v := vehicle.NewRepo(r.db)
-	s := starship.NewRepo(r.db)
 
+	peoples, err := r.buildPeoples(stmt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM people `+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return peoples, count, nil
+}
+
+// PeoplesByFilmIDs gets, in a constant number of queries, the peoples associated to each of the films
+func (r Repository) PeoplesByFilmIDs(ids []int) (map[int][]People, error) {
+	byFilm := make(map[int][]People, len(ids))
+	if len(ids) == 0 {
+		return byFilm, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, height, mass, hair_color, skin_color, eye_color, birth_year, gender, homeworld, created, edited, url, fp.films
+        FROM films_people fp
+            INNER JOIN people p ON fp.people = p.id
+        WHERE fp.films IN `+in+`
+        ORDER BY created`, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	peoples := make([]People, 0)
+	films := make([]int, 0)
 	for {
 		hasRow, err := stmt.Step()
 		if err != nil {
-			log.Fatal("Step gave error :" + err.Error())
+			return nil, failure.Unavailable("Step gave error", err)
 		}
 		if !hasRow {
 			break
 		}
 
-		p := buildPeople(stmt)
-		p.Vehicles = v.AllVehiclesByPeopleID(p.ID)
-		p.Starships = s.AllStarshipsByPeopleID(p.ID)
+		var film int
+		p, err := buildPeople(stmt, &film)
+		if err != nil {
+			return nil, err
+		}
 		peoples = append(peoples, p)
+		films = append(films, film)
+	}
+	if err := r.embed(peoples); err != nil {
+		return nil, err
+	}
+	for i, p := range peoples {
+		byFilm[films[i]] = append(byFilm[films[i]], p)
 	}
 
-	return peoples
+	return byFilm, nil
 }
 
-// PostPeople set one people into storage
-func (r Repository) PostPeople(p People) int {
-	l, err := r.lastPeople()
-	var futureID int
-	if err != nil {
-		futureID = 1
-	} else {
-		futureID = (*l).ID + 1
+// buildPeoples walks through a statement, then embeds vehicles, starships, species and homeworld of all peoples at once
+func (r Repository) buildPeoples(stmt d.Stmt) ([]People, error) {
+	peoples := make([]People, 0)
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		p, err := buildPeople(stmt)
+		if err != nil {
+			return nil, err
+		}
+		peoples = append(peoples, p)
+	}
+	if err := r.embed(peoples); err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	date := now.Format(time.RFC3339)
-	stmt, err := r.db.Prepare(`INSERT INTO people
-        (id, name, height, mass, hair_color, skin_color, eye_color, birth_year, gender, homeworld, created, edited, url)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
-	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+	return peoples, nil
+}
+
+// embed attaches vehicles, starships, species and homeworld of peoples, each relation being fetched in one go.
+// Relations the view does not expand are only referenced, and those it hides are not fetched at all,
+// but for the ids of assignments, which the entity tag needs whatever the view
+func (r Repository) embed(peoples []People) error {
+	v := r.view
+	ids := make([]int, 0, len(peoples))
+	homeworlds := make([]int, 0, len(peoples))
+	for i := range peoples {
+		peoples[i].view = v
+		peoples[i].refs = make(map[string][]int)
+		ids = append(ids, peoples[i].ID)
+		homeworlds = append(homeworlds, peoples[i].Homeworld)
 	}
-	defer stmt.Close()
 
-	err = stmt.Exec(
-		futureID,
-		p.Name,
-		p.Height,
-		p.Mass,
-		p.Hair,
-		p.Skin,
-		p.Eye,
-		p.BirthYear,
-		p.Gender,
-		p.Homeworld,
-		date,
-		date,
-		p.URL,
-	)
+	for _, l := range relations {
+		if embedded := v.shows(l.column) && v.expands(l.column); embedded || !(v.shows(l.column) || contains(versioned, l.column)) {
+			continue
+		}
+		refs, err := r.references(l, ids)
+		if err != nil {
+			return err
+		}
+		for i := range peoples {
+			peoples[i].refs[l.column] = refs[peoples[i].ID]
+		}
+	}
 
-	if err != nil {
-		log.Fatal("Failed to exec SQL :" + err.Error())
+	var err error
+	var vehicles map[int][]vehicle.Vehicle
+	if v.shows("vehicles") && v.expands("vehicles") {
+		if vehicles, err = vehicle.NewRepo(r.db).VehiclesByPeopleIDs(ids); err != nil {
+			return err
+		}
+	}
+	var starships map[int][]starship.Starship
+	if v.shows("starships") && v.expands("starships") {
+		if starships, err = starship.NewRepo(r.db).StarshipsByPeopleIDs(ids); err != nil {
+			return err
+		}
+	}
+	var ss map[int][]species.Species
+	if v.shows("species") && v.expands("species") {
+		if ss, err = species.NewRepo(r.db).SpeciesByPeopleIDs(ids); err != nil {
+			return err
+		}
+	}
+	var planets map[int]planet.Planet
+	if v.shows("_homeworld") && v.expands("homeworld") {
+		if planets, err = planet.NewRepo(r.db).PlanetsByIDs(homeworlds); err != nil {
+			return err
+		}
 	}
 
-	return futureID
+	for i := range peoples {
+		p := &peoples[i]
+		if p.Vehicles = vehicles[p.ID]; p.Vehicles == nil {
+			p.Vehicles = make([]vehicle.Vehicle, 0)
+		}
+		if vehicles != nil {
+			for _, v := range p.Vehicles {
+				p.refs["vehicles"] = append(p.refs["vehicles"], v.ID)
+			}
+		}
+		if p.Starships = starships[p.ID]; p.Starships == nil {
+			p.Starships = make([]starship.Starship, 0)
+		}
+		if starships != nil {
+			for _, s := range p.Starships {
+				p.refs["starships"] = append(p.refs["starships"], s.ID)
+			}
+		}
+		if p.Species = ss[p.ID]; p.Species == nil {
+			p.Species = make([]species.Species, 0)
+		}
+		if hw, ok := planets[p.Homeworld]; ok {
+			p.Planet = &hw
+		}
+	}
+
+	return nil
 }
 
-// lastPeople fetches last people from storage
-func (r Repository) lastPeople() (*People, error) {
-	stmt, err := r.db.Prepare(`SELECT id, name, height, mass, hair_color, skin_color, eye_color, birth_year, gender, homeworld, created, edited, url
-        FROM people
-        ORDER BY created DESC
-        LIMIT 1`)
-	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+// PostPeople set one people, with its vehicles and starships, into storage. Its species are read-only
+func (r Repository) PostPeople(p People) (int, error) {
+	if err := checkReadOnly(p); err != nil {
+		return 0, err
 	}
-	defer stmt.Close()
+	var id int
+	err := d.Atomically(r, func(t Repository) error {
+		if err := t.checkHomeworld(p); err != nil {
+			return err
+		}
+		if err := t.checkAssignments(p); err != nil {
+			return err
+		}
 
-	hasRow, err := stmt.Step()
+		date := time.Now().Format(time.RFC3339)
+		var err error
+		id, err = t.db.Insert("people",
+			[]string{"name", "height", "mass", "hair_color", "skin_color", "eye_color", "birth_year", "gender", "homeworld", "created", "edited", "url"},
+			p.Name,
+			p.Height,
+			p.Mass,
+			p.Hair,
+			p.Skin,
+			p.Eye,
+			p.BirthYear,
+			p.Gender,
+			p.Homeworld,
+			date,
+			date,
+			p.URL,
+		)
+		if err != nil {
+			return err
+		}
+
+		return t.replaceAssignments(id, p)
+	})
 	if err != nil {
-		log.Fatal("Step gave error :" + err.Error())
-	}
-	if !hasRow {
-		return nil, errors.New("No people in storage")
+		return 0, err
 	}
 
-	p := buildPeople(stmt)
-	return &p, nil
+	return id, nil
 }
 
 // PeopleByID fetches one people from storage
@@ -148,65 +297,85 @@ func (r Repository) PeopleByID(id int) (*People, error) {
         WHERE id = ?
         ORDER BY created`, id)
 	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+		return nil, failure.Unavailable("Malformed SQL", err)
 	}
 	defer stmt.Close()
 
 	hasRow, err := stmt.Step()
 	if err != nil {
-		log.Fatal("Step gave error :" + err.Error())
+		return nil, failure.Unavailable("Step gave error", err)
 	}
 	if !hasRow {
-		return nil, errors.New("Unknown id")
+		return nil, failure.NotFound("People", id)
 	}
-	v := vehicle.NewRepo(r.db)
-	s := starship.NewRepo(r.db)
 
-	p := buildPeople(stmt)
-	p.Vehicles = v.AllVehiclesByPeopleID(p.ID)
-	p.Starships = s.AllStarshipsByPeopleID(p.ID)
-	return &p, nil
+	p, err := buildPeople(stmt)
+	if err != nil {
+		return nil, err
+	}
+	peoples := []People{p}
+	if err := r.embed(peoples); err != nil {
+		return nil, err
+	}
+	return &peoples[0], nil
 }
 
-// PutPeople updates a people into storage
-func (r Repository) PutPeople(id int, p People) error {
-	now := time.Now()
-	_, err := r.PeopleByID(id)
-	if err != nil {
-		return err
+// checkHomeworld ensures the homeworld of a people, when given, is a known planet
+func (r Repository) checkHomeworld(p People) error {
+	if p.Homeworld == 0 {
+		return nil
 	}
 
-	stmt, err := r.db.Prepare(`UPDATE people
-        SET name = ?, height = ?, mass = ?, hair_color = ?, skin_color = ?, eye_color = ?, birth_year = ?, gender = ?, homeworld = ?, edited = ?, url = ?
-        WHERE id = ?`)
-	if err != nil {
-		return errors.New("Failed to prepare :" + err.Error())
+	_, err := planet.NewRepo(r.db).PlanetByID(p.Homeworld)
+	if errors.Is(err, failure.ErrNotFound) {
+		return failure.Invalid(map[string]string{"homeworld": "Unknown planet #" + strconv.Itoa(p.Homeworld)})
 	}
-	defer stmt.Close()
 
-	err = stmt.Exec(
-		p.Name,
-		p.Height,
-		p.Mass,
-		p.Hair,
-		p.Skin,
-		p.Eye,
-		p.BirthYear,
-		p.Gender,
-		p.Homeworld,
-		now.Format(time.RFC3339),
-		p.URL,
-		id,
-	)
+	return err
+}
 
-	if err != nil {
-		return errors.New("Failed to exec SQL :" + err.Error())
+// PutPeople updates a people into storage. Vehicles and starships are replaced only when given, species are read-only
+func (r Repository) PutPeople(id int, p People) error {
+	if err := checkReadOnly(p); err != nil {
+		return err
 	}
 
-	return nil
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+		if err := t.checkHomeworld(p); err != nil {
+			return err
+		}
+		if err := t.checkAssignments(p); err != nil {
+			return err
+		}
+
+		err := d.Exec(t.db, `UPDATE people
+        SET name = ?, height = ?, mass = ?, hair_color = ?, skin_color = ?, eye_color = ?, birth_year = ?, gender = ?, homeworld = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			p.Name,
+			p.Height,
+			p.Mass,
+			p.Hair,
+			p.Skin,
+			p.Eye,
+			p.BirthYear,
+			p.Gender,
+			p.Homeworld,
+			time.Now().Format(time.RFC3339),
+			p.URL,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+
+		return t.replaceAssignments(id, p)
+	})
 }
 
-func buildPeople(s d.Stmt) People {
+func buildPeople(s d.Stmt, extra ...interface{}) (People, error) {
 	var id int
 	var name string
 	var height int
@@ -221,9 +390,9 @@ func buildPeople(s d.Stmt) People {
 	var edited string
 	var url string
 
-	err := s.Scan(&id, &name, &height, &mass, &hair, &skin, &eye, &birthYear, &gender, &homeworld, &created, &edited, &url)
+	err := s.Scan(append([]interface{}{&id, &name, &height, &mass, &hair, &skin, &eye, &birthYear, &gender, &homeworld, &created, &edited, &url}, extra...)...)
 	if err != nil {
-		log.Fatal("Scan gave error :" + err.Error())
+		return People{}, failure.Unavailable("Scan gave error", err)
 	}
 
 	return People{
@@ -240,25 +409,19 @@ func buildPeople(s d.Stmt) People {
 		Created:   created,
 		Edited:    edited,
 		URL:       url,
-	}
+	}, nil
 }
 
-// DeletePeople unsets a people from storage
+// DeletePeople unsets a people from storage, its links being deleted or blocking as the delete policy says
 func (r Repository) DeletePeople(id int) error {
-	_, err := r.PeopleByID(id)
-	if err != nil {
-		return err
-	}
-
-	stmt, err := r.db.Prepare(`DELETE FROM people WHERE id = ?`)
-	if err != nil {
-		return errors.New("Failed to prepare :" + err.Error())
-	}
-	defer stmt.Close()
-
-	if err = stmt.Exec(id); err != nil {
-		return errors.New("Failed to exec SQL :" + err.Error())
-	}
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.current(id); err != nil {
+			return err
+		}
+		if err := t.unlink(id); err != nil {
+			return err
+		}
 
-	return nil
+		return d.Exec(t.db, `DELETE FROM people WHERE id = ?`, id)
+	})
 }
@@ -2,9 +2,11 @@ package people
 
 import (
 	"errors"
+	"log/slog"
 	"testing"
 
 	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
 )
 
 type DataDouble struct{}
@@ -15,15 +17,33 @@ func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, err
 	return StmtDouble{}, nil
 }
 
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
 func (s StmtDouble) Close() error {
 	return nil
 }
 
 var step int
 
+var stepErr error
+
 func (s StmtDouble) Step() (bool, error) {
 	step++
-	return (step <= 2), nil
+	return (step <= 2), stepErr
 }
 
 var exec error
@@ -40,28 +60,43 @@ var repo = NewRepo(DataDouble{})
 
 func TestAllPeoplesOK(t *testing.T) {
 	step = 1
-	ps := repo.AllPeoples()
-	if len(ps) != 1 {
+	ps, _, err := repo.AllPeoples(database.NewPage(1, 10), Filter{}, Sort{})
+	if err != nil || len(ps) != 1 {
 		t.Error("No people")
 	}
 }
 
 func TestAllPeoplesKO(t *testing.T) {
 	step = 3
-	ps := repo.AllPeoples()
-	if len(ps) != 0 {
+	ps, _, err := repo.AllPeoples(database.NewPage(1, 10), Filter{}, Sort{})
+	if err != nil || len(ps) != 0 {
 		t.Error("There's people")
 	}
 }
 
-func TestPostPeopleNoLast(t *testing.T) {
+func TestPostPeopleOK(t *testing.T) {
 	step = 2
 	p := People{
 		ID:   97,
 		Name: "Boba Fett",
 	}
-	if id := repo.PostPeople(p); id != 1 {
-		t.Error("There's people in storage")
+	if id, err := repo.PostPeople(p); err != nil || id != 1 {
+		t.Error("Id not allocated by storage")
+	}
+}
+
+func TestPostPeopleConflict(t *testing.T) {
+	exec = failure.Conflict("Id 1 already taken in people")
+	defer func() { exec = nil }()
+	if _, err := repo.PostPeople(People{Name: "Boba Fett"}); !errors.Is(err, failure.ErrConflict) {
+		t.Error("Conflict not surfaced")
+	}
+}
+
+func TestPostPeopleUnknownHomeworld(t *testing.T) {
+	step = 2
+	if _, err := repo.PostPeople(People{Name: "Boba Fett", Homeworld: 99}); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Found planet with this id")
 	}
 }
 
@@ -74,11 +109,29 @@ func TestPeopleByIDOK(t *testing.T) {
 
 func TestPeopleByIDKO(t *testing.T) {
 	step = 2
-	if _, err := repo.PeopleByID(7); err == nil {
+	if _, err := repo.PeopleByID(7); !errors.Is(err, failure.ErrNotFound) {
 		t.Error("There's people with this id")
 	}
 }
 
+func TestPeopleByIDUnavailable(t *testing.T) {
+	step = 1
+	stepErr = errors.New("database is locked")
+	defer func() { stepErr = nil }()
+	if _, err := repo.PeopleByID(7); !errors.Is(err, failure.ErrUnavailable) {
+		t.Error("Storage failure not propagated")
+	}
+}
+
+func TestAllPeoplesUnavailable(t *testing.T) {
+	step = 1
+	stepErr = errors.New("database is locked")
+	defer func() { stepErr = nil }()
+	if _, _, err := repo.AllPeoples(database.NewPage(1, 10), Filter{}, Sort{}); !errors.Is(err, failure.ErrUnavailable) {
+		t.Error("Storage failure not propagated")
+	}
+}
+
 func TestPutPeopleNoPeople(t *testing.T) {
 	step = 2
 	p := People{
@@ -137,3 +190,99 @@ func TestDeletePeopleOK(t *testing.T) {
 		t.Error("Delete failed")
 	}
 }
+
+func TestPeoplesByFilmIDsOK(t *testing.T) {
+	step = 1
+	byFilm, err := repo.PeoplesByFilmIDs([]int{1})
+	if err != nil || len(byFilm[0]) != 1 {
+		t.Error("No people for this film")
+	}
+}
+
+func TestPeoplesByFilmIDsKO(t *testing.T) {
+	step = 3
+	byFilm, err := repo.PeoplesByFilmIDs([]int{1})
+	if err != nil || len(byFilm) != 0 {
+		t.Error("There's people for this film")
+	}
+}
+
+// CountingDouble counts the queries run, each giving as many rows as asked
+type CountingDouble struct {
+	rows    int
+	queries *int
+}
+
+type CountingStmtDouble struct {
+	rows int
+	row  int
+}
+
+func (d CountingDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	*d.queries++
+	return &CountingStmtDouble{rows: d.rows}, nil
+}
+
+func (d CountingDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, nil
+}
+
+func (d CountingDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d CountingDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d CountingDouble) Close() error {
+	return nil
+}
+
+func (s *CountingStmtDouble) Close() error {
+	return nil
+}
+
+func (s *CountingStmtDouble) Step() (bool, error) {
+	s.row++
+	return s.row <= s.rows, nil
+}
+
+func (s *CountingStmtDouble) Exec(...interface{}) error {
+	return nil
+}
+
+// Scan gives the row number to every integer, so that each people has its own id
+func (s *CountingStmtDouble) Scan(dst ...interface{}) error {
+	for _, v := range dst {
+		if i, ok := v.(*int); ok {
+			*i = s.row
+		}
+	}
+	return nil
+}
+
+func queriesListing(peoples int) int {
+	var queries int
+	r := NewRepo(CountingDouble{rows: peoples, queries: &queries})
+	r.AllPeoples(database.NewPage(1, database.MaxLimit), Filter{}, Sort{})
+
+	return queries
+}
+
+func TestAllPeoplesConstantQueries(t *testing.T) {
+	if few, many := queriesListing(1), queriesListing(database.MaxLimit); few != many {
+		t.Errorf("Listing 1 people takes %d queries, %d peoples %d", few, database.MaxLimit, many)
+	}
+}
+
+func BenchmarkAllPeoples(b *testing.B) {
+	var queries int
+	r := NewRepo(CountingDouble{rows: database.MaxLimit, queries: &queries})
+	for i := 0; i < b.N; i++ {
+		if _, _, err := r.AllPeoples(database.NewPage(1, database.MaxLimit), Filter{}, Sort{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+}
@@ -0,0 +1,63 @@
+package people
+
+import (
+	"strings"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// Sort orders a list of peoples, each key being a column name, prefixed by "-" for a descending order
+type Sort []string
+
+// sortable maps sort keys to their SQL expression, casting numbers stored as text
+var sortable = map[string]string{
+	"id":        "CAST(id AS INTEGER)",
+	"name":      "name",
+	"height":    "CAST(height AS REAL)",
+	"mass":      "CAST(REPLACE(mass, ',', '') AS REAL)",
+	"hair":      "hair_color",
+	"skin":      "skin_color",
+	"eye":       "eye_color",
+	"gender":    "gender",
+	"homeworld": "CAST(homeworld AS INTEGER)",
+	"created":   "created",
+	"edited":    "edited",
+}
+
+// NewSort reads a comma separated list of sort keys, such as "name,-height"
+func NewSort(s string) Sort {
+	if s == "" {
+		return Sort{}
+	}
+
+	return Sort(strings.Split(s, ","))
+}
+
+// Validate ensures every sort key is known
+func (s Sort) Validate() error {
+	for _, key := range s {
+		if _, ok := sortable[strings.TrimPrefix(key, "-")]; !ok {
+			return failure.Validation("Unknown sort key " + key)
+		}
+	}
+
+	return nil
+}
+
+// orderBy translates known sort keys into a SQL ordering, falling back on creation date
+func (s Sort) orderBy() string {
+	terms := make([]string, 0, len(s)+1)
+	for _, key := range s {
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
+			key = key[1:]
+		}
+		if expr, ok := sortable[key]; ok {
+			terms = append(terms, expr+" "+direction)
+		}
+	}
+	terms = append(terms, "created ASC")
+
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
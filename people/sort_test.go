@@ -0,0 +1,34 @@
+package people
+
+import "testing"
+
+func TestNewSortEmpty(t *testing.T) {
+	if s := NewSort(""); len(s) != 0 {
+		t.Error("Empty sort has keys")
+	}
+}
+
+func TestSortValidateOK(t *testing.T) {
+	if err := NewSort("name,-height").Validate(); err != nil {
+		t.Error("Valid sort rejected")
+	}
+}
+
+func TestSortValidateUnknown(t *testing.T) {
+	if err := NewSort("name; DROP TABLE people").Validate(); err == nil {
+		t.Error("Unknown sort key accepted")
+	}
+}
+
+func TestSortOrderByDefault(t *testing.T) {
+	if o := NewSort("").orderBy(); o != "ORDER BY created ASC" {
+		t.Error("Wrong default ordering : " + o)
+	}
+}
+
+func TestSortOrderBy(t *testing.T) {
+	o := NewSort("-height,name").orderBy()
+	if o != "ORDER BY CAST(height AS REAL) DESC, name ASC, created ASC" {
+		t.Error("Wrong ordering : " + o)
+	}
+}
@@ -0,0 +1,61 @@
+package people
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// genders are the acceptable genders of a people
+var genders = []string{"male", "female", "hermaphrodite", "none", "na", "unknown"}
+
+// birthYear matches a year counted from the battle of Yavin, like 19BBY or 4ABY
+var birthYear = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?(BBY|ABY)|unknown)$`)
+
+// Validate ensures a people is acceptable, explaining each wrong field by its JSON name
+func (p People) Validate() error {
+	fields := make(map[string]string)
+	if strings.TrimSpace(p.Name) == "" {
+		fields["name"] = "Required"
+	}
+	if p.Height < 0 {
+		fields["height"] = "Must not be negative"
+	}
+	if p.Mass < 0 {
+		fields["mass"] = "Must not be negative"
+	}
+	if p.Homeworld < 0 {
+		fields["homeworld"] = "Must not be negative"
+	}
+	if !knownGender(p.Gender) {
+		fields["gender"] = "Must be one of " + strings.Join(genders, ", ")
+	}
+	if !birthYear.MatchString(p.BirthYear) {
+		fields["birth_year"] = "Must be a year like 19BBY or 4ABY, or unknown"
+	}
+	if len(fields) > 0 {
+		return failure.Invalid(fields)
+	}
+
+	return nil
+}
+
+// checkReadOnly ensures a people to store assigns no species, species not being written through a people
+func checkReadOnly(p People) error {
+	if len(p.Species) > 0 {
+		return failure.Invalid(map[string]string{"species": "Read-only field, must be empty"})
+	}
+
+	return nil
+}
+
+func knownGender(gender string) bool {
+	for _, g := range genders {
+		if g == gender {
+			return true
+		}
+	}
+
+	return false
+}
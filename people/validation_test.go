@@ -0,0 +1,47 @@
+package people
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prytoegrian/swapi/failure"
+	"github.com/prytoegrian/swapi/species"
+)
+
+func TestValidateOK(t *testing.T) {
+	p := People{Name: "Boba Fett", Height: 183, Mass: 78, Gender: "male", BirthYear: "31.5BBY", Homeworld: 10}
+	if err := p.Validate(); err != nil {
+		t.Error("Valid people refused : " + err.Error())
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	p := People{Name: " ", Height: -1, Gender: "droid", BirthYear: "1990"}
+	var f *failure.Error
+	if err := p.Validate(); !errors.As(err, &f) || !errors.Is(err, failure.ErrValidation) {
+		t.Fatal("Invalid people accepted")
+	}
+	for _, field := range []string{"name", "height", "gender", "birth_year"} {
+		if _, ok := f.Fields[field]; !ok {
+			t.Error("Field not reported : " + field)
+		}
+	}
+	if _, ok := f.Fields["mass"]; ok {
+		t.Error("Valid field reported")
+	}
+}
+
+func TestPostPeopleSpeciesReadOnly(t *testing.T) {
+	p := People{Name: "Boba Fett", Species: []species.Species{{ID: 1}}}
+	var f *failure.Error
+	if _, err := repo.PostPeople(p); !errors.As(err, &f) || f.Fields["species"] == "" {
+		t.Error("Species silently dropped")
+	}
+}
+
+func TestPutPeopleNoSpecies(t *testing.T) {
+	step = 1
+	if err := repo.PutPeople(1, People{Species: []species.Species{}}); err != nil {
+		t.Error("Empty species refused : " + err.Error())
+	}
+}
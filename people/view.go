@@ -0,0 +1,170 @@
+package people
+
+import (
+	"encoding/json"
+	"strings"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// Expand lists the relations of a people to embed, the others being given by id. Nil embeds them all
+type Expand []string
+
+// Fields lists the fields of a people to show, id being always shown. Nil shows them all
+type Fields []string
+
+// View tells which relations of a people are embedded and which fields are shown
+type View struct {
+	Expand Expand
+	Fields Fields
+}
+
+// expandable are the relations which may be embedded
+var expandable = []string{"vehicles", "starships", "species", "homeworld"}
+
+// visible are the JSON names of the fields of a people
+var visible = []string{"id", "name", "height", "mass", "hair", "skin", "eye", "birth_year", "gender", "homeworld", "_homeworld", "films", "species", "vehicles", "starships", "_created", "_edited", "url"}
+
+// NewExpand reads the values of an expand parameter, such as "vehicles,homeworld". No value at all expands everything
+func NewExpand(values []string) Expand {
+	if values == nil {
+		return nil
+	}
+
+	return Expand(list(values[0]))
+}
+
+// NewFields reads a comma separated list of fields, such as "name,height"
+func NewFields(s string) Fields {
+	if s == "" {
+		return nil
+	}
+
+	return Fields(list(s))
+}
+
+func list(s string) []string {
+	l := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			l = append(l, item)
+		}
+	}
+
+	return l
+}
+
+// Validate ensures every relation and field asked is known
+func (v View) Validate() error {
+	for _, rel := range v.Expand {
+		if !contains(expandable, rel) {
+			return failure.Validation("Unknown relation " + rel)
+		}
+	}
+	for _, f := range v.Fields {
+		if !contains(visible, f) {
+			return failure.Validation("Unknown field " + f)
+		}
+	}
+
+	return nil
+}
+
+func (v View) expands(rel string) bool {
+	return v.Expand == nil || contains(v.Expand, rel)
+}
+
+func (v View) shows(field string) bool {
+	return v.Fields == nil || field == "id" || contains(v.Fields, field)
+}
+
+func (v View) whole() bool {
+	return v.Expand == nil && v.Fields == nil
+}
+
+func contains(l []string, s string) bool {
+	for _, item := range l {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Viewing gives a repository fetching peoples as the view says, skipping the relations it does not need
+func (r Repository) Viewing(v View) Repository {
+	r.view = v
+
+	return r
+}
+
+// references fetches, in one query and without joining, the ids of the resources each people is linked to
+func (r Repository) references(l link, ids []int) (map[int][]int, error) {
+	refs := make(map[int][]int, len(ids))
+	if len(ids) == 0 {
+		return refs, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT people, `+l.column+` FROM `+l.table+` WHERE people IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var people, resourceID int
+		if err := stmt.Scan(&people, &resourceID); err != nil {
+			return nil, failure.Unavailable("Scan gave error", err)
+		}
+		refs[people] = append(refs[people], resourceID)
+	}
+
+	return refs, nil
+}
+
+// MarshalJSON represents a people as its view says : relations not embedded are given by id, hidden fields are left aside
+func (p People) MarshalJSON() ([]byte, error) {
+	type plain People
+	m, err := json.Marshal(plain(p))
+	if err != nil || p.view.whole() {
+		return m, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(m, &doc); err != nil {
+		return nil, err
+	}
+	for _, l := range relations {
+		if p.view.expands(l.column) {
+			continue
+		}
+		ids := p.refs[l.column]
+		if ids == nil {
+			ids = make([]int, 0)
+		}
+		if doc[l.column], err = json.Marshal(ids); err != nil {
+			return nil, err
+		}
+	}
+	if !p.view.expands("homeworld") {
+		delete(doc, "_homeworld")
+	}
+	for field := range doc {
+		if !p.view.shows(field) {
+			delete(doc, field)
+		}
+	}
+
+	return json.Marshal(doc)
+}
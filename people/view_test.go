@@ -0,0 +1,50 @@
+package people
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+func TestNewExpandAbsent(t *testing.T) {
+	if e := NewExpand(nil); e != nil {
+		t.Error("Absent expand does not embed all")
+	}
+	if e := NewExpand([]string{""}); e == nil || len(e) != 0 {
+		t.Error("Empty expand embeds something")
+	}
+}
+
+func TestViewValidateUnknown(t *testing.T) {
+	if err := (View{Expand: Expand{"films"}}).Validate(); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Unknown relation accepted")
+	}
+	if err := (View{Fields: NewFields("name,hair_color")}).Validate(); !errors.Is(err, failure.ErrValidation) {
+		t.Error("Unknown field accepted")
+	}
+}
+
+func TestMarshalJSONView(t *testing.T) {
+	p := People{
+		ID:   22,
+		Name: "Boba Fett",
+		view: View{Expand: Expand{}, Fields: Fields{"name", "starships", "_homeworld"}},
+		refs: map[string][]int{"starships": {21}},
+	}
+	m, err := json.Marshal(p)
+	if err != nil || string(m) != `{"id":22,"name":"Boba Fett","starships":[21]}` {
+		t.Error("Wrong representation : " + string(m))
+	}
+}
+
+// Hidden relations are not fetched, but for the ids of vehicles and starships the entity tag needs
+func TestAllPeoplesSkipsHiddenRelations(t *testing.T) {
+	var queries int
+	r := NewRepo(CountingDouble{rows: 3, queries: &queries}).Viewing(View{Fields: Fields{"name"}})
+	if _, _, err := r.AllPeoples(database.NewPage(1, 10), Filter{}, Sort{}); err != nil || queries != 4 {
+		t.Errorf("Hidden relations fetched, %d queries", queries)
+	}
+}
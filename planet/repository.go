@@ -0,0 +1,376 @@
+package planet
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// NewRepo initialises a new planet repository
+func NewRepo(db d.Database) Repository {
+	return Repository{
+		db: db,
+	}
+}
+
+// Repository is a planet repository
+type Repository struct {
+	db d.Database
+}
+
+// Database is the database the planet repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the planet repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
+}
+
+// Planet represents a well-formed planet
+type Planet struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	RotationPeriod string     `json:"rotation_period"`
+	OrbitalPeriod  string     `json:"orbital_period"`
+	Diameter       string     `json:"diameter"`
+	Climate        string     `json:"climate"`
+	Gravity        string     `json:"gravity"`
+	Terrain        string     `json:"terrain"`
+	SurfaceWater   string     `json:"surface_water"`
+	Population     string     `json:"population"`
+	Residents      []Resident `json:"residents"`
+	Films          string     `json:"films"`
+	Created        string     `json:"_created"`
+	Edited         string     `json:"_edited"`
+	URL            string     `json:"url"`
+}
+
+// Resident represents a people living on a planet
+type Resident struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// AllPlanets fetches a page of planets from storage, along with their total count
+func (r Repository) AllPlanets(page d.Page) ([]Planet, int, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, rotation_period, orbital_period, diameter, climate, gravity, terrain, surface_water, population, created, edited, url
+        FROM planets
+        ORDER BY created
+        LIMIT ? OFFSET ?`, page.Limit, page.Offset())
+	if err != nil {
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	ps, err := r.buildPlanets(stmt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM planets`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ps, count, nil
+}
+
+// PlanetByID fetches one planet from storage
+func (r Repository) PlanetByID(id int) (*Planet, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, rotation_period, orbital_period, diameter, climate, gravity, terrain, surface_water, population, created, edited, url
+        FROM planets
+        WHERE id = ?`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return nil, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return nil, failure.NotFound("Planet", id)
+	}
+
+	p, err := buildPlanet(stmt)
+	if err != nil {
+		return nil, err
+	}
+	ps := []Planet{p}
+	if err := r.embed(ps); err != nil {
+		return nil, err
+	}
+	return &ps[0], nil
+}
+
+// PlanetsByIDs fetches, in a constant number of queries, the planets having the ids, keyed by id.
+// Unknown ids are left aside
+func (r Repository) PlanetsByIDs(ids []int) (map[int]Planet, error) {
+	ps := make(map[int]Planet, len(ids))
+	if len(ids) == 0 {
+		return ps, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, rotation_period, orbital_period, diameter, climate, gravity, terrain, surface_water, population, created, edited, url
+        FROM planets
+        WHERE id IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	planets, err := r.buildPlanets(stmt)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range planets {
+		ps[p.ID] = p
+	}
+
+	return ps, nil
+}
+
+// PostPlanet set one planet into storage
+func (r Repository) PostPlanet(p Planet) (int, error) {
+	date := time.Now().Format(time.RFC3339)
+	return r.db.Insert("planets",
+		[]string{"name", "rotation_period", "orbital_period", "diameter", "climate", "gravity", "terrain", "surface_water", "population", "created", "edited", "url"},
+		p.Name,
+		p.RotationPeriod,
+		p.OrbitalPeriod,
+		p.Diameter,
+		p.Climate,
+		p.Gravity,
+		p.Terrain,
+		p.SurfaceWater,
+		p.Population,
+		date,
+		date,
+		p.URL,
+	)
+}
+
+// PutPlanet updates a planet into storage
+func (r Repository) PutPlanet(id int, p Planet) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.PlanetByID(id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `UPDATE planets
+        SET name = ?, rotation_period = ?, orbital_period = ?, diameter = ?, climate = ?, gravity = ?, terrain = ?, surface_water = ?, population = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			p.Name,
+			p.RotationPeriod,
+			p.OrbitalPeriod,
+			p.Diameter,
+			p.Climate,
+			p.Gravity,
+			p.Terrain,
+			p.SurfaceWater,
+			p.Population,
+			time.Now().Format(time.RFC3339),
+			p.URL,
+			id,
+		)
+	})
+}
+
+// DeletePlanet unsets a planet, and its links to films, from storage.
+// A planet still home of peoples is kept, failing with a conflict listing its residents
+func (r Repository) DeletePlanet(id int) error {
+	return d.Atomically(r, func(t Repository) error {
+		p, err := t.PlanetByID(id)
+		if err != nil {
+			return err
+		}
+		if len(p.Residents) > 0 {
+			residents := make([]string, 0, len(p.Residents))
+			for _, res := range p.Residents {
+				residents = append(residents, strconv.Itoa(res.ID))
+			}
+
+			return failure.Conflict("Planet #" + strconv.Itoa(id) + " still home of people #" + strings.Join(residents, ", #"))
+		}
+
+		if err := d.Exec(t.db, `DELETE FROM films_planets WHERE planets = ?`, id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `DELETE FROM planets WHERE id = ?`, id)
+	})
+}
+
+// PlanetsByFilmIDs gets, in a constant number of queries, the planets associated to each of the films
+func (r Repository) PlanetsByFilmIDs(ids []int) (map[int][]Planet, error) {
+	byFilm := make(map[int][]Planet, len(ids))
+	if len(ids) == 0 {
+		return byFilm, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, rotation_period, orbital_period, diameter, climate, gravity, terrain, surface_water, population, created, edited, url, fp.films
+        FROM films_planets fp
+            INNER JOIN planets p ON fp.planets = p.id
+        WHERE fp.films IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	ps := make([]Planet, 0)
+	films := make([]int, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var film int
+		p, err := buildPlanet(stmt, &film)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+		films = append(films, film)
+	}
+	if err := r.embed(ps); err != nil {
+		return nil, err
+	}
+	for i, p := range ps {
+		byFilm[films[i]] = append(byFilm[films[i]], p)
+	}
+
+	return byFilm, nil
+}
+
+// residents fetches, in one query, the peoples living on each of the planets
+func (r Repository) residents(ids []int) (map[int][]Resident, error) {
+	rs := make(map[int][]Resident, len(ids))
+	if len(ids) == 0 {
+		return rs, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, url, homeworld
+        FROM people
+        WHERE homeworld IN `+in+`
+        ORDER BY created`, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var res Resident
+		var homeworld int
+		if err := stmt.Scan(&res.ID, &res.Name, &res.URL, &homeworld); err != nil {
+			return nil, failure.Unavailable("Scan gave error", err)
+		}
+		rs[homeworld] = append(rs[homeworld], res)
+	}
+
+	return rs, nil
+}
+
+// embed attaches residents of planets, fetched all at once
+func (r Repository) embed(ps []Planet) error {
+	ids := make([]int, 0, len(ps))
+	for _, p := range ps {
+		ids = append(ids, p.ID)
+	}
+	rs, err := r.residents(ids)
+	if err != nil {
+		return err
+	}
+	for i := range ps {
+		if ps[i].Residents = rs[ps[i].ID]; ps[i].Residents == nil {
+			ps[i].Residents = make([]Resident, 0)
+		}
+	}
+
+	return nil
+}
+
+// buildPlanets walks through a statement, then embeds residents of all planets at once
+func (r Repository) buildPlanets(stmt d.Stmt) ([]Planet, error) {
+	ps := make([]Planet, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		p, err := buildPlanet(stmt)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	if err := r.embed(ps); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func buildPlanet(s d.Stmt, extra ...interface{}) (Planet, error) {
+	var id int
+	var name string
+	var rotationPeriod string
+	var orbitalPeriod string
+	var diameter string
+	var climate string
+	var gravity string
+	var terrain string
+	var surfaceWater string
+	var population string
+	var created string
+	var edited string
+	var url string
+
+	err := s.Scan(append([]interface{}{&id, &name, &rotationPeriod, &orbitalPeriod, &diameter, &climate, &gravity, &terrain, &surfaceWater, &population, &created, &edited, &url}, extra...)...)
+	if err != nil {
+		return Planet{}, failure.Unavailable("Scan gave error", err)
+	}
+
+	return Planet{
+		ID:             id,
+		Name:           name,
+		RotationPeriod: rotationPeriod,
+		OrbitalPeriod:  orbitalPeriod,
+		Diameter:       diameter,
+		Climate:        climate,
+		Gravity:        gravity,
+		Terrain:        terrain,
+		SurfaceWater:   surfaceWater,
+		Population:     population,
+		Created:        created,
+		Edited:         edited,
+		URL:            url,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package planet
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+type DataDouble struct{}
+
+type StmtDouble struct{}
+
+func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	return StmtDouble{}, nil
+}
+
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
+func (s StmtDouble) Close() error {
+	return nil
+}
+
+var step int
+
+func (s StmtDouble) Step() (bool, error) {
+	step++
+	return (step <= 2), nil
+}
+
+var exec error
+
+func (s StmtDouble) Exec(...interface{}) error {
+	return exec
+}
+
+func (s StmtDouble) Scan(dst ...interface{}) error {
+	return nil
+}
+
+var repo = NewRepo(DataDouble{})
+
+func TestAllPlanetsOK(t *testing.T) {
+	step = 1
+	ps, _, err := repo.AllPlanets(database.NewPage(1, 10))
+	if err != nil || len(ps) != 1 {
+		t.Error("No planet")
+	}
+}
+
+func TestAllPlanetsKO(t *testing.T) {
+	step = 3
+	ps, _, err := repo.AllPlanets(database.NewPage(1, 10))
+	if err != nil || len(ps) != 0 {
+		t.Error("There's planet")
+	}
+}
+
+func TestPlanetByIDOK(t *testing.T) {
+	step = 1
+	if _, err := repo.PlanetByID(1); err != nil {
+		t.Error("There's no planet with this id")
+	}
+}
+
+func TestPlanetByIDKO(t *testing.T) {
+	step = 2
+	if _, err := repo.PlanetByID(1); err == nil {
+		t.Error("There's planet with this id")
+	}
+}
+
+func TestPlanetByIDResidents(t *testing.T) {
+	step = 0
+	p, err := repo.PlanetByID(1)
+	if err != nil {
+		t.Fatal("There's no planet with this id")
+	}
+	if len(p.Residents) != 1 {
+		t.Error("No resident on this planet")
+	}
+}
+
+func TestPlanetsByIDsOK(t *testing.T) {
+	step = 1
+	ps, err := repo.PlanetsByIDs([]int{1, 2})
+	if err != nil || len(ps) != 1 {
+		t.Error("No planet with these ids")
+	}
+}
+
+func TestPlanetsByIDsNone(t *testing.T) {
+	step = 1
+	ps, err := repo.PlanetsByIDs(nil)
+	if err != nil || len(ps) != 0 {
+		t.Error("There's planet without id")
+	}
+}
+
+func TestPostPlanetFail(t *testing.T) {
+	step = 0
+	exec = errors.New("")
+	if _, err := repo.PostPlanet(Planet{Name: "Jakku"}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPostPlanetOK(t *testing.T) {
+	step = 0
+	exec = nil
+	if _, err := repo.PostPlanet(Planet{Name: "Jakku"}); err != nil {
+		t.Error("Post failed")
+	}
+}
+
+func TestPutPlanetNoPlanet(t *testing.T) {
+	step = 2
+	if err := repo.PutPlanet(3, Planet{}); err == nil {
+		t.Error("Found planet with this id")
+	}
+}
+
+func TestPutPlanetFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.PutPlanet(3, Planet{}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPutPlanetOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.PutPlanet(3, Planet{}); err != nil {
+		t.Error("Put failed")
+	}
+}
+
+func TestDeletePlanetNoPlanet(t *testing.T) {
+	step = 2
+	if err := repo.DeletePlanet(3); err == nil {
+		t.Error("Found planet with this id")
+	}
+}
+
+func TestDeletePlanetFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.DeletePlanet(3); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestDeletePlanetOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.DeletePlanet(3); err != nil {
+		t.Error("Delete failed")
+	}
+}
+
+func TestDeletePlanetResidents(t *testing.T) {
+	step = 0
+	exec = nil
+	if err := repo.DeletePlanet(1); !errors.Is(err, failure.ErrConflict) {
+		t.Error("Planet deleted despite its residents")
+	}
+}
+
+func TestPlanetsByFilmIDsGrouped(t *testing.T) {
+	step = 0
+	byFilm, err := repo.PlanetsByFilmIDs([]int{1, 2})
+	if err != nil || len(byFilm) != 1 || len(byFilm[0]) != 2 {
+		t.Error("Planets not grouped by film")
+	}
+}
+
+func TestPlanetsByFilmIDsNone(t *testing.T) {
+	byFilm, err := repo.PlanetsByFilmIDs(nil)
+	if err != nil || len(byFilm) != 0 {
+		t.Error("Planets without film")
+	}
+}
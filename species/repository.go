@@ -0,0 +1,274 @@
+package species
+
+import (
+	"time"
+
+	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
+)
+
+// NewRepo initialises a new species repository
+func NewRepo(db d.Database) Repository {
+	return Repository{
+		db: db,
+	}
+}
+
+// Repository is a species repository
+type Repository struct {
+	db d.Database
+}
+
+// Database is the database the species repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the species repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
+}
+
+// Species represents a well-formed species
+type Species struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Classification  string `json:"classification"`
+	Designation     string `json:"designation"`
+	AverageHeight   string `json:"average_height"`
+	SkinColors      string `json:"skin_colors"`
+	HairColors      string `json:"hair_colors"`
+	EyeColors       string `json:"eye_colors"`
+	AverageLifespan string `json:"average_lifespan"`
+	Homeworld       int    `json:"homeworld"`
+	Language        string `json:"language"`
+	People          string `json:"people"`
+	Films           string `json:"films"`
+	Created         string `json:"_created"`
+	Edited          string `json:"_edited"`
+	URL             string `json:"url"`
+}
+
+// AllSpecies fetches a page of species from storage, along with their total count
+func (r Repository) AllSpecies(page d.Page) ([]Species, int, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, classification, designation, average_height, skin_colors, hair_colors, eye_colors, average_lifespan, homeworld, language, created, edited, url
+        FROM species
+        ORDER BY created
+        LIMIT ? OFFSET ?`, page.Limit, page.Offset())
+	if err != nil {
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	ss, err := buildAllSpecies(stmt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM species`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ss, count, nil
+}
+
+// SpeciesByID fetches one species from storage
+func (r Repository) SpeciesByID(id int) (*Species, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, classification, designation, average_height, skin_colors, hair_colors, eye_colors, average_lifespan, homeworld, language, created, edited, url
+        FROM species
+        WHERE id = ?`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return nil, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return nil, failure.NotFound("Species", id)
+	}
+
+	s, err := buildSpecies(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PostSpecies set one species into storage
+func (r Repository) PostSpecies(s Species) (int, error) {
+	date := time.Now().Format(time.RFC3339)
+	return r.db.Insert("species",
+		[]string{"name", "classification", "designation", "average_height", "skin_colors", "hair_colors", "eye_colors", "average_lifespan", "homeworld", "language", "created", "edited", "url"},
+		s.Name,
+		s.Classification,
+		s.Designation,
+		s.AverageHeight,
+		s.SkinColors,
+		s.HairColors,
+		s.EyeColors,
+		s.AverageLifespan,
+		s.Homeworld,
+		s.Language,
+		date,
+		date,
+		s.URL,
+	)
+}
+
+// PutSpecies updates a species into storage
+func (r Repository) PutSpecies(id int, s Species) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.SpeciesByID(id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `UPDATE species
+        SET name = ?, classification = ?, designation = ?, average_height = ?, skin_colors = ?, hair_colors = ?, eye_colors = ?, average_lifespan = ?, homeworld = ?, language = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			s.Name,
+			s.Classification,
+			s.Designation,
+			s.AverageHeight,
+			s.SkinColors,
+			s.HairColors,
+			s.EyeColors,
+			s.AverageLifespan,
+			s.Homeworld,
+			s.Language,
+			time.Now().Format(time.RFC3339),
+			s.URL,
+			id,
+		)
+	})
+}
+
+// DeleteSpecies unsets a species, and its links to peoples and films, from storage
+func (r Repository) DeleteSpecies(id int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.SpeciesByID(id); err != nil {
+			return err
+		}
+
+		if err := d.Exec(t.db, `DELETE FROM people_species WHERE species = ?`, id); err != nil {
+			return err
+		}
+		if err := d.Exec(t.db, `DELETE FROM films_species WHERE species = ?`, id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `DELETE FROM species WHERE id = ?`, id)
+	})
+}
+
+// SpeciesByPeopleIDs gets, in one query, the species associated to each of the peoples
+func (r Repository) SpeciesByPeopleIDs(ids []int) (map[int][]Species, error) {
+	return r.grouped("people_species", "people", ids)
+}
+
+// SpeciesByFilmIDs gets, in one query, the species associated to each of the films
+func (r Repository) SpeciesByFilmIDs(ids []int) (map[int][]Species, error) {
+	return r.grouped("films_species", "films", ids)
+}
+
+// grouped gets, in one query, the species linked through a join table to each of the owners, keyed by owner
+func (r Repository) grouped(join string, owner string, ids []int) (map[int][]Species, error) {
+	ss := make(map[int][]Species, len(ids))
+	if len(ids) == 0 {
+		return ss, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, classification, designation, average_height, skin_colors, hair_colors, eye_colors, average_lifespan, homeworld, language, created, edited, url, j.`+owner+`
+        FROM `+join+` j
+            INNER JOIN species s ON j.species = s.id
+        WHERE j.`+owner+` IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var id int
+		s, err := buildSpecies(stmt, &id)
+		if err != nil {
+			return nil, err
+		}
+		ss[id] = append(ss[id], s)
+	}
+
+	return ss, nil
+}
+
+func buildAllSpecies(stmt d.Stmt) ([]Species, error) {
+	ss := make([]Species, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		s, err := buildSpecies(stmt)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+func buildSpecies(s d.Stmt, extra ...interface{}) (Species, error) {
+	var id int
+	var name string
+	var classification string
+	var designation string
+	var averageHeight string
+	var skinColors string
+	var hairColors string
+	var eyeColors string
+	var averageLifespan string
+	var homeworld int
+	var language string
+	var created string
+	var edited string
+	var url string
+
+	err := s.Scan(append([]interface{}{&id, &name, &classification, &designation, &averageHeight, &skinColors, &hairColors, &eyeColors, &averageLifespan, &homeworld, &language, &created, &edited, &url}, extra...)...)
+	if err != nil {
+		return Species{}, failure.Unavailable("Scan gave error", err)
+	}
+
+	return Species{
+		ID:              id,
+		Name:            name,
+		Classification:  classification,
+		Designation:     designation,
+		AverageHeight:   averageHeight,
+		SkinColors:      skinColors,
+		HairColors:      hairColors,
+		EyeColors:       eyeColors,
+		AverageLifespan: averageLifespan,
+		Homeworld:       homeworld,
+		Language:        language,
+		Created:         created,
+		Edited:          edited,
+		URL:             url,
+	}, nil
+}
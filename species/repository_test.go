@@ -0,0 +1,179 @@
+package species
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prytoegrian/swapi/database"
+)
+
+type DataDouble struct{}
+
+type StmtDouble struct{}
+
+func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, error) {
+	return StmtDouble{}, nil
+}
+
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
+func (s StmtDouble) Close() error {
+	return nil
+}
+
+var step int
+
+func (s StmtDouble) Step() (bool, error) {
+	step++
+	return (step <= 2), nil
+}
+
+var exec error
+
+func (s StmtDouble) Exec(...interface{}) error {
+	return exec
+}
+
+func (s StmtDouble) Scan(dst ...interface{}) error {
+	return nil
+}
+
+var repo = NewRepo(DataDouble{})
+
+func TestAllSpeciesOK(t *testing.T) {
+	step = 0
+	ss, _, err := repo.AllSpecies(database.NewPage(1, 10))
+	if err != nil || len(ss) != 2 {
+		t.Error("No species")
+	}
+}
+
+func TestAllSpeciesKO(t *testing.T) {
+	step = 3
+	ss, _, err := repo.AllSpecies(database.NewPage(1, 10))
+	if err != nil || len(ss) != 0 {
+		t.Error("There's species")
+	}
+}
+
+func TestSpeciesByIDOK(t *testing.T) {
+	step = 1
+	if _, err := repo.SpeciesByID(2); err != nil {
+		t.Error("There's no species with this id")
+	}
+}
+
+func TestSpeciesByIDKO(t *testing.T) {
+	step = 2
+	if _, err := repo.SpeciesByID(2); err == nil {
+		t.Error("There's species with this id")
+	}
+}
+
+func TestPostSpeciesFail(t *testing.T) {
+	step = 0
+	exec = errors.New("")
+	if _, err := repo.PostSpecies(Species{Name: "Porg"}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPostSpeciesOK(t *testing.T) {
+	step = 0
+	exec = nil
+	if _, err := repo.PostSpecies(Species{Name: "Porg"}); err != nil {
+		t.Error("Post failed")
+	}
+}
+
+func TestPutSpeciesNoSpecies(t *testing.T) {
+	step = 2
+	if err := repo.PutSpecies(2, Species{}); err == nil {
+		t.Error("Found species with this id")
+	}
+}
+
+func TestPutSpeciesFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.PutSpecies(2, Species{}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPutSpeciesOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.PutSpecies(2, Species{}); err != nil {
+		t.Error("Put failed")
+	}
+}
+
+func TestDeleteSpeciesNoSpecies(t *testing.T) {
+	step = 2
+	if err := repo.DeleteSpecies(2); err == nil {
+		t.Error("Found species with this id")
+	}
+}
+
+func TestDeleteSpeciesFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.DeleteSpecies(2); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestDeleteSpeciesOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.DeleteSpecies(2); err != nil {
+		t.Error("Delete failed")
+	}
+}
+
+func TestSpeciesByPeopleIDsGrouped(t *testing.T) {
+	step = 0
+	byPeople, err := repo.SpeciesByPeopleIDs([]int{88, 89})
+	if err != nil || len(byPeople) != 1 || len(byPeople[0]) != 2 {
+		t.Error("Species not grouped by people")
+	}
+}
+
+func TestSpeciesByPeopleIDsNone(t *testing.T) {
+	step = 0
+	byPeople, err := repo.SpeciesByPeopleIDs(nil)
+	if err != nil || len(byPeople) != 0 {
+		t.Error("There's species without people")
+	}
+}
+
+func TestSpeciesByFilmIDsGrouped(t *testing.T) {
+	step = 0
+	byFilm, err := repo.SpeciesByFilmIDs([]int{1, 2})
+	if err != nil || len(byFilm) != 1 || len(byFilm[0]) != 2 {
+		t.Error("Species not grouped by film")
+	}
+}
+
+func TestSpeciesByFilmIDsNone(t *testing.T) {
+	byFilm, err := repo.SpeciesByFilmIDs(nil)
+	if err != nil || len(byFilm) != 0 {
+		t.Error("Species without film")
+	}
+}
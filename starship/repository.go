@@ -1,9 +1,10 @@
 package starship
 
 import (
-	"log"
+	"time"
 
 	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
 )
 
 // NewRepo initialises a new starship repository
@@ -18,6 +19,18 @@ type Repository struct {
 	db d.Database
 }
 
+// Database is the database the starship repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the starship repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
+}
+
 // Starship represents a well-formed starship
 type Starship struct {
 	ID                   int    `json:"id"`
@@ -34,43 +47,277 @@ type Starship struct {
 	HyperdriveRating     string `json:"hyperdrive_rating"`
 	MGLT                 string `json:"mglt"`
 	StarshipClass        string `json:"starship_class"`
-	Pilots               string `json:"pilots"`
-	Films                string `json:"films"`
-	Created              string `json:"_created"`
-	Edited               string `json:"_edited"`
-	URL                  string `json:"url"`
+	// Pilots is left out when the starship is embedded in another resource
+	Pilots  *[]Pilot `json:"pilots,omitempty"`
+	Films   string   `json:"films"`
+	Created string   `json:"_created"`
+	Edited  string   `json:"_edited"`
+	URL     string   `json:"url"`
 }
 
-// AllStarshipsByPeopleID get all starships associated to a people
-func (r Repository) AllStarshipsByPeopleID(id int) []Starship {
-	ss := make([]Starship, 0)
+// Pilot represents a people piloting a starship
+type Pilot struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
 
+// AllStarships fetches a page of starships from storage, along with their total count
+func (r Repository) AllStarships(page d.Page) ([]Starship, int, error) {
 	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, hyperdrive_rating, mglt, starship_class, created, edited, url
-        FROM people_starships ps
-            INNER JOIN starships s ON ps.starships = s.id
-        WHERE ps.people = ?`, id)
+        FROM starships
+        ORDER BY created
+        LIMIT ? OFFSET ?`, page.Limit, page.Offset())
+	if err != nil {
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	ss, err := buildStarships(stmt)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := r.embed(ss); err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM starships`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ss, count, nil
+}
+
+// StarshipByID fetches one starship from storage
+func (r Repository) StarshipByID(id int) (*Starship, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, hyperdrive_rating, mglt, starship_class, created, edited, url
+        FROM starships
+        WHERE id = ?`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return nil, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return nil, failure.NotFound("Starship", id)
+	}
+
+	s, err := buildStarship(stmt)
+	if err != nil {
+		return nil, err
+	}
+	ss := []Starship{s}
+	if err := r.embed(ss); err != nil {
+		return nil, err
+	}
+	return &ss[0], nil
+}
+
+// PostStarship set one starship into storage
+func (r Repository) PostStarship(s Starship) (int, error) {
+	date := time.Now().Format(time.RFC3339)
+	return r.db.Insert("starships",
+		[]string{"name", "model", "manufacturer", "cost_in_credits", "length", "max_atmosphering_speed", "crew", "passengers", "cargo_capacity", "consumables", "hyperdrive_rating", "mglt", "starship_class", "created", "edited", "url"},
+		s.Name,
+		s.Model,
+		s.Manufacturer,
+		s.CostInCredits,
+		s.Length,
+		s.MaxAtmospheringSpeed,
+		s.Crew,
+		s.Passengers,
+		s.CargoCapacity,
+		s.Consumables,
+		s.HyperdriveRating,
+		s.MGLT,
+		s.StarshipClass,
+		date,
+		date,
+		s.URL,
+	)
+}
+
+// PutStarship updates a starship into storage
+func (r Repository) PutStarship(id int, s Starship) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.StarshipByID(id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `UPDATE starships
+        SET name = ?, model = ?, manufacturer = ?, cost_in_credits = ?, length = ?, max_atmosphering_speed = ?, crew = ?, passengers = ?, cargo_capacity = ?, consumables = ?, hyperdrive_rating = ?, mglt = ?, starship_class = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			s.Name,
+			s.Model,
+			s.Manufacturer,
+			s.CostInCredits,
+			s.Length,
+			s.MaxAtmospheringSpeed,
+			s.Crew,
+			s.Passengers,
+			s.CargoCapacity,
+			s.Consumables,
+			s.HyperdriveRating,
+			s.MGLT,
+			s.StarshipClass,
+			time.Now().Format(time.RFC3339),
+			s.URL,
+			id,
+		)
+	})
+}
+
+// DeleteStarship unsets a starship, and its links to peoples and films, from storage
+func (r Repository) DeleteStarship(id int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.StarshipByID(id); err != nil {
+			return err
+		}
+
+		if err := d.Exec(t.db, `DELETE FROM people_starships WHERE starships = ?`, id); err != nil {
+			return err
+		}
+		if err := d.Exec(t.db, `DELETE FROM films_starships WHERE starships = ?`, id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `DELETE FROM starships WHERE id = ?`, id)
+	})
+}
+
+// pilots fetches, in one query, the peoples piloting each of the starships
+func (r Repository) pilots(ids []int) (map[int][]Pilot, error) {
+	ps := make(map[int][]Pilot, len(ids))
+	if len(ids) == 0 {
+		return ps, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, url, j.starships
+        FROM people_starships j
+            INNER JOIN people p ON j.people = p.id
+        WHERE j.starships IN `+in+`
+        ORDER BY created`, args...)
 	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+		return nil, failure.Unavailable("Malformed SQL", err)
 	}
 	defer stmt.Close()
 
 	for {
 		hasRow, err := stmt.Step()
 		if err != nil {
-			log.Fatal("Step gave error :" + err.Error())
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var p Pilot
+		var id int
+		if err := stmt.Scan(&p.ID, &p.Name, &p.URL, &id); err != nil {
+			return nil, failure.Unavailable("Scan gave error", err)
+		}
+		ps[id] = append(ps[id], p)
+	}
+
+	return ps, nil
+}
+
+// embed attaches pilots of starships, fetched all at once
+func (r Repository) embed(ss []Starship) error {
+	ids := make([]int, 0, len(ss))
+	for _, s := range ss {
+		ids = append(ids, s.ID)
+	}
+	ps, err := r.pilots(ids)
+	if err != nil {
+		return err
+	}
+	for i := range ss {
+		pilots := ps[ss[i].ID]
+		if pilots == nil {
+			pilots = make([]Pilot, 0)
+		}
+		ss[i].Pilots = &pilots
+	}
+
+	return nil
+}
+
+// StarshipsByPeopleIDs gets, in one query, the starships associated to each of the peoples
+func (r Repository) StarshipsByPeopleIDs(ids []int) (map[int][]Starship, error) {
+	return r.grouped("people_starships", "people", ids)
+}
+
+// StarshipsByFilmIDs gets, in one query, the starships associated to each of the films
+func (r Repository) StarshipsByFilmIDs(ids []int) (map[int][]Starship, error) {
+	return r.grouped("films_starships", "films", ids)
+}
+
+// grouped gets, in one query, the starships linked through a join table to each of the owners, keyed by owner
+func (r Repository) grouped(join string, owner string, ids []int) (map[int][]Starship, error) {
+	ss := make(map[int][]Starship, len(ids))
+	if len(ids) == 0 {
+		return ss, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, hyperdrive_rating, mglt, starship_class, created, edited, url, j.`+owner+`
+        FROM `+join+` j
+            INNER JOIN starships s ON j.starships = s.id
+        WHERE j.`+owner+` IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var id int
+		s, err := buildStarship(stmt, &id)
+		if err != nil {
+			return nil, err
+		}
+		ss[id] = append(ss[id], s)
+	}
+
+	return ss, nil
+}
+
+func buildStarships(stmt d.Stmt) ([]Starship, error) {
+	ss := make([]Starship, 0)
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
 		}
 		if !hasRow {
 			// The query is finished
 			break
 		}
-		s := buildStarship(stmt)
+		s, err := buildStarship(stmt)
+		if err != nil {
+			return nil, err
+		}
 		ss = append(ss, s)
 	}
 
-	return ss
+	return ss, nil
 }
 
-func buildStarship(stmt d.Stmt) Starship {
+func buildStarship(stmt d.Stmt, extra ...interface{}) (Starship, error) {
 	// Use Scan to access column data from a row
 	var id int
 	var name string
@@ -90,11 +337,10 @@ func buildStarship(stmt d.Stmt) Starship {
 	var edited string
 	var url string
 
-	err := stmt.Scan(&id, &name, &model, &manufacturer, &costInCredits, &length, &maxAtmospheringSpeed, &crew, &passengers, &cargoCapacity, &consumables, &hyperdriveRating, &mglt, &starshipClass, &created, &edited, &url)
+	err := stmt.Scan(append([]interface{}{&id, &name, &model, &manufacturer, &costInCredits, &length, &maxAtmospheringSpeed, &crew, &passengers, &cargoCapacity, &consumables, &hyperdriveRating, &mglt, &starshipClass, &created, &edited, &url}, extra...)...)
 	if err != nil {
-		log.Fatal("Scan gave error :" + err.Error())
+		return Starship{}, failure.Unavailable("Scan gave error", err)
 	}
-	// improvement : mass fetching of starships for all id people
 	return Starship{
 		ID:                   id,
 		Name:                 name,
@@ -113,5 +359,5 @@ func buildStarship(stmt d.Stmt) Starship {
 		Created:              created,
 		Edited:               edited,
 		URL:                  url,
-	}
+	}, nil
 }
@@ -1,6 +1,10 @@
 package starship
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/prytoegrian/swapi/database"
@@ -14,6 +18,22 @@ func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, err
 	return StmtDouble{}, nil
 }
 
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
 func (s StmtDouble) Close() error {
 	return nil
 }
@@ -25,8 +45,10 @@ func (s StmtDouble) Step() (bool, error) {
 	return (step <= 2), nil
 }
 
+var exec error
+
 func (s StmtDouble) Exec(...interface{}) error {
-	return nil
+	return exec
 }
 
 func (s StmtDouble) Scan(dst ...interface{}) error {
@@ -35,18 +57,155 @@ func (s StmtDouble) Scan(dst ...interface{}) error {
 
 var repo = NewRepo(DataDouble{})
 
-func TestAllStarshipsByPeopleIDOK(t *testing.T) {
+func TestStarshipsByPeopleIDsGrouped(t *testing.T) {
 	step = 0
-	ss := repo.AllStarshipsByPeopleID(88)
-	if len(ss) != 2 {
-		t.Error("No starship for this people")
+	byPeople, err := repo.StarshipsByPeopleIDs([]int{88, 89})
+	if err != nil || len(byPeople) != 1 || len(byPeople[0]) != 2 {
+		t.Error("Starships not grouped by people")
 	}
 }
 
-func TestAllStarshipsByPeopleIDKO(t *testing.T) {
+func TestStarshipsByPeopleIDsNone(t *testing.T) {
+	step = 0
+	byPeople, err := repo.StarshipsByPeopleIDs(nil)
+	if err != nil || len(byPeople) != 0 {
+		t.Error("There's starship without people")
+	}
+}
+
+func TestStarshipsByFilmIDsGrouped(t *testing.T) {
+	step = 0
+	byFilm, err := repo.StarshipsByFilmIDs([]int{1, 2})
+	if err != nil || len(byFilm) != 1 || len(byFilm[0]) != 2 {
+		t.Error("Starships not grouped by film")
+	}
+}
+
+func TestStarshipsByFilmIDsNone(t *testing.T) {
+	byFilm, err := repo.StarshipsByFilmIDs(nil)
+	if err != nil || len(byFilm) != 0 {
+		t.Error("Starships without film")
+	}
+}
+
+func TestAllStarshipsOK(t *testing.T) {
+	step = 1
+	ss, _, err := repo.AllStarships(database.NewPage(1, 10))
+	if err != nil || len(ss) != 1 {
+		t.Error("No starship")
+	}
+}
+
+func TestAllStarshipsKO(t *testing.T) {
 	step = 3
-	ss := repo.AllStarshipsByPeopleID(88)
-	if len(ss) != 0 {
-		t.Error("There's starship for this people")
+	ss, _, err := repo.AllStarships(database.NewPage(1, 10))
+	if err != nil || len(ss) != 0 {
+		t.Error("There's starship")
+	}
+}
+
+func TestStarshipByIDOK(t *testing.T) {
+	step = 1
+	if _, err := repo.StarshipByID(10); err != nil {
+		t.Error("There's no starship with this id")
+	}
+}
+
+func TestStarshipByIDKO(t *testing.T) {
+	step = 2
+	if _, err := repo.StarshipByID(10); err == nil {
+		t.Error("There's starship with this id")
+	}
+}
+
+func TestStarshipByIDPilots(t *testing.T) {
+	step = 0
+	s, err := repo.StarshipByID(10)
+	if err != nil {
+		t.Fatal("There's no starship with this id")
+	}
+	if s.Pilots == nil || len(*s.Pilots) != 1 {
+		t.Error("No pilot for this starship")
+	}
+}
+
+func TestStarshipByIDNoPilot(t *testing.T) {
+	step = 1
+	s, err := repo.StarshipByID(10)
+	if err != nil {
+		t.Fatal("There's no starship with this id")
+	}
+	if b, _ := json.Marshal(s); !strings.Contains(string(b), `"pilots":[]`) {
+		t.Error("Pilots not listed : " + string(b))
+	}
+}
+
+func TestStarshipsByPeopleIDsNoPilots(t *testing.T) {
+	step = 1
+	byPeople, _ := repo.StarshipsByPeopleIDs([]int{88})
+	if b, _ := json.Marshal(byPeople[0]); strings.Contains(string(b), `"pilots"`) {
+		t.Error("Pilots of an embedded starship listed : " + string(b))
+	}
+}
+
+func TestPostStarshipFail(t *testing.T) {
+	step = 0
+	exec = errors.New("")
+	if _, err := repo.PostStarship(Starship{Name: "Millennium Falcon"}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPostStarshipOK(t *testing.T) {
+	step = 0
+	exec = nil
+	if _, err := repo.PostStarship(Starship{Name: "Millennium Falcon"}); err != nil {
+		t.Error("Post failed")
+	}
+}
+
+func TestPutStarshipNoStarship(t *testing.T) {
+	step = 2
+	if err := repo.PutStarship(10, Starship{}); err == nil {
+		t.Error("Found starship with this id")
+	}
+}
+
+func TestPutStarshipFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.PutStarship(10, Starship{}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPutStarshipOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.PutStarship(10, Starship{}); err != nil {
+		t.Error("Put failed")
+	}
+}
+
+func TestDeleteStarshipNoStarship(t *testing.T) {
+	step = 2
+	if err := repo.DeleteStarship(10); err == nil {
+		t.Error("Found starship with this id")
+	}
+}
+
+func TestDeleteStarshipFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.DeleteStarship(10); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestDeleteStarshipOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.DeleteStarship(10); err != nil {
+		t.Error("Delete failed")
 	}
 }
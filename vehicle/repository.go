@@ -1,9 +1,10 @@
 package vehicle
 
 import (
-	"log"
+	"time"
 
 	d "github.com/prytoegrian/swapi/database"
+	"github.com/prytoegrian/swapi/failure"
 )
 
 // NewRepo initialises a new vehicle repository
@@ -18,6 +19,18 @@ type Repository struct {
 	db d.Database
 }
 
+// Database is the database the vehicle repository works against
+func (r Repository) Database() d.Database {
+	return r.db
+}
+
+// WithDatabase gives the vehicle repository working against db
+func (r Repository) WithDatabase(db d.Database) Repository {
+	r.db = db
+
+	return r
+}
+
 // Vehicle represents a well-formed vehicle
 type Vehicle struct {
 	ID                   int    `json:"id"`
@@ -32,44 +45,273 @@ type Vehicle struct {
 	CargoCapacity        string `json:"cargo_capacity"`
 	Consumables          string `json:"consumables"`
 	VehicleClass         string `json:"vehicle_class"`
-	Pilots               string `json:"pilots"`
-	Films                string `json:"films"`
-	Created              string `json:"_created"`
-	Edited               string `json:"_edited"`
-	URL                  string `json:"url"`
+	// Pilots is left out when the vehicle is embedded in another resource
+	Pilots  *[]Pilot `json:"pilots,omitempty"`
+	Films   string   `json:"films"`
+	Created string   `json:"_created"`
+	Edited  string   `json:"_edited"`
+	URL     string   `json:"url"`
 }
 
-// AllVehiclesByPeopleID get all vehicles associated to a people
-func (r Repository) AllVehiclesByPeopleID(id int) []Vehicle {
-	vs := make([]Vehicle, 0)
+// Pilot represents a people piloting a vehicle
+type Pilot struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
 
+// AllVehicles fetches a page of vehicles from storage, along with their total count
+func (r Repository) AllVehicles(page d.Page) ([]Vehicle, int, error) {
 	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, vehicle_class, created, edited, url
-        FROM people_vehicles pv
-            INNER JOIN vehicles v ON pv.vehicles = v.id
-        WHERE pv.people = ?`, id)
+        FROM vehicles
+        ORDER BY created
+        LIMIT ? OFFSET ?`, page.Limit, page.Offset())
+	if err != nil {
+		return nil, 0, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	vs, err := buildVehicles(stmt)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := r.embed(vs); err != nil {
+		return nil, 0, err
+	}
+
+	count, err := d.Count(r.db, `SELECT COUNT(*) FROM vehicles`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return vs, count, nil
+}
+
+// VehicleByID fetches one vehicle from storage
+func (r Repository) VehicleByID(id int) (*Vehicle, error) {
+	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, vehicle_class, created, edited, url
+        FROM vehicles
+        WHERE id = ?`, id)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return nil, failure.Unavailable("Step gave error", err)
+	}
+	if !hasRow {
+		return nil, failure.NotFound("Vehicle", id)
+	}
+
+	v, err := buildVehicle(stmt)
+	if err != nil {
+		return nil, err
+	}
+	vs := []Vehicle{v}
+	if err := r.embed(vs); err != nil {
+		return nil, err
+	}
+	return &vs[0], nil
+}
+
+// PostVehicle set one vehicle into storage
+func (r Repository) PostVehicle(v Vehicle) (int, error) {
+	date := time.Now().Format(time.RFC3339)
+	return r.db.Insert("vehicles",
+		[]string{"name", "model", "manufacturer", "cost_in_credits", "length", "max_atmosphering_speed", "crew", "passengers", "cargo_capacity", "consumables", "vehicle_class", "created", "edited", "url"},
+		v.Name,
+		v.Model,
+		v.Manufacturer,
+		v.CostInCredits,
+		v.Length,
+		v.MaxAtmospheringSpeed,
+		v.Crew,
+		v.Passengers,
+		v.CargoCapacity,
+		v.Consumables,
+		v.VehicleClass,
+		date,
+		date,
+		v.URL,
+	)
+}
+
+// PutVehicle updates a vehicle into storage
+func (r Repository) PutVehicle(id int, v Vehicle) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.VehicleByID(id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `UPDATE vehicles
+        SET name = ?, model = ?, manufacturer = ?, cost_in_credits = ?, length = ?, max_atmosphering_speed = ?, crew = ?, passengers = ?, cargo_capacity = ?, consumables = ?, vehicle_class = ?, edited = ?, url = ?
+        WHERE id = ?`,
+			v.Name,
+			v.Model,
+			v.Manufacturer,
+			v.CostInCredits,
+			v.Length,
+			v.MaxAtmospheringSpeed,
+			v.Crew,
+			v.Passengers,
+			v.CargoCapacity,
+			v.Consumables,
+			v.VehicleClass,
+			time.Now().Format(time.RFC3339),
+			v.URL,
+			id,
+		)
+	})
+}
+
+// DeleteVehicle unsets a vehicle, and its links to peoples and films, from storage
+func (r Repository) DeleteVehicle(id int) error {
+	return d.Atomically(r, func(t Repository) error {
+		if _, err := t.VehicleByID(id); err != nil {
+			return err
+		}
+
+		if err := d.Exec(t.db, `DELETE FROM people_vehicles WHERE vehicles = ?`, id); err != nil {
+			return err
+		}
+		if err := d.Exec(t.db, `DELETE FROM films_vehicles WHERE vehicles = ?`, id); err != nil {
+			return err
+		}
+
+		return d.Exec(t.db, `DELETE FROM vehicles WHERE id = ?`, id)
+	})
+}
+
+// pilots fetches, in one query, the peoples piloting each of the vehicles
+func (r Repository) pilots(ids []int) (map[int][]Pilot, error) {
+	ps := make(map[int][]Pilot, len(ids))
+	if len(ids) == 0 {
+		return ps, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, url, j.vehicles
+        FROM people_vehicles j
+            INNER JOIN people p ON j.people = p.id
+        WHERE j.vehicles IN `+in+`
+        ORDER BY created`, args...)
 	if err != nil {
-		log.Fatal("Malformed SQL :" + err.Error())
+		return nil, failure.Unavailable("Malformed SQL", err)
 	}
 	defer stmt.Close()
 
 	for {
 		hasRow, err := stmt.Step()
 		if err != nil {
-			log.Fatal("Step gave error :" + err.Error())
+			return nil, failure.Unavailable("Step gave error", err)
 		}
 		if !hasRow {
 			break
 		}
 
-		// improvement : mass fetching of vehicle for all id people
-		v := buildVehicle(stmt)
+		var p Pilot
+		var id int
+		if err := stmt.Scan(&p.ID, &p.Name, &p.URL, &id); err != nil {
+			return nil, failure.Unavailable("Scan gave error", err)
+		}
+		ps[id] = append(ps[id], p)
+	}
+
+	return ps, nil
+}
+
+// embed attaches pilots of vehicles, fetched all at once
+func (r Repository) embed(vs []Vehicle) error {
+	ids := make([]int, 0, len(vs))
+	for _, v := range vs {
+		ids = append(ids, v.ID)
+	}
+	ps, err := r.pilots(ids)
+	if err != nil {
+		return err
+	}
+	for i := range vs {
+		pilots := ps[vs[i].ID]
+		if pilots == nil {
+			pilots = make([]Pilot, 0)
+		}
+		vs[i].Pilots = &pilots
+	}
+
+	return nil
+}
+
+// VehiclesByPeopleIDs gets, in one query, the vehicles associated to each of the peoples
+func (r Repository) VehiclesByPeopleIDs(ids []int) (map[int][]Vehicle, error) {
+	return r.grouped("people_vehicles", "people", ids)
+}
+
+// VehiclesByFilmIDs gets, in one query, the vehicles associated to each of the films
+func (r Repository) VehiclesByFilmIDs(ids []int) (map[int][]Vehicle, error) {
+	return r.grouped("films_vehicles", "films", ids)
+}
+
+// grouped gets, in one query, the vehicles linked through a join table to each of the owners, keyed by owner
+func (r Repository) grouped(join string, owner string, ids []int) (map[int][]Vehicle, error) {
+	vs := make(map[int][]Vehicle, len(ids))
+	if len(ids) == 0 {
+		return vs, nil
+	}
+
+	in, args := d.In(ids)
+	stmt, err := r.db.Prepare(`SELECT id, name, model, manufacturer, cost_in_credits, length, max_atmosphering_speed, crew, passengers, cargo_capacity, consumables, vehicle_class, created, edited, url, j.`+owner+`
+        FROM `+join+` j
+            INNER JOIN vehicles v ON j.vehicles = v.id
+        WHERE j.`+owner+` IN `+in, args...)
+	if err != nil {
+		return nil, failure.Unavailable("Malformed SQL", err)
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		var id int
+		v, err := buildVehicle(stmt, &id)
+		if err != nil {
+			return nil, err
+		}
+		vs[id] = append(vs[id], v)
+	}
+
+	return vs, nil
+}
+
+func buildVehicles(s d.Stmt) ([]Vehicle, error) {
+	vs := make([]Vehicle, 0)
+	for {
+		hasRow, err := s.Step()
+		if err != nil {
+			return nil, failure.Unavailable("Step gave error", err)
+		}
+		if !hasRow {
+			break
+		}
+
+		v, err := buildVehicle(s)
+		if err != nil {
+			return nil, err
+		}
 		vs = append(vs, v)
 	}
 
-	return vs
+	return vs, nil
 }
 
-func buildVehicle(s d.Stmt) Vehicle {
+func buildVehicle(s d.Stmt, extra ...interface{}) (Vehicle, error) {
 	var id int
 	var name string
 	var model string
@@ -86,9 +328,9 @@ func buildVehicle(s d.Stmt) Vehicle {
 	var edited string
 	var url string
 
-	err := s.Scan(&id, &name, &model, &manufacturer, &costInCredits, &length, &maxAtmospheringSpeed, &crew, &passengers, &cargoCapacity, &consumables, &vehicleClass, &created, &edited, &url)
+	err := s.Scan(append([]interface{}{&id, &name, &model, &manufacturer, &costInCredits, &length, &maxAtmospheringSpeed, &crew, &passengers, &cargoCapacity, &consumables, &vehicleClass, &created, &edited, &url}, extra...)...)
 	if err != nil {
-		log.Fatal("Scan gave error :" + err.Error())
+		return Vehicle{}, failure.Unavailable("Scan gave error", err)
 	}
 
 	return Vehicle{
@@ -107,5 +349,5 @@ func buildVehicle(s d.Stmt) Vehicle {
 		Created:              created,
 		Edited:               edited,
 		URL:                  url,
-	}
+	}, nil
 }
@@ -1,6 +1,10 @@
 package vehicle
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/prytoegrian/swapi/database"
@@ -14,6 +18,22 @@ func (d DataDouble) Prepare(sql string, args ...interface{}) (database.Stmt, err
 	return StmtDouble{}, nil
 }
 
+func (d DataDouble) Insert(table string, columns []string, values ...interface{}) (int, error) {
+	return 1, exec
+}
+
+func (d DataDouble) WithTx(f func(database.Database) error) error {
+	return f(d)
+}
+
+func (d DataDouble) WithLogger(*slog.Logger) database.Database {
+	return d
+}
+
+func (d DataDouble) Close() error {
+	return nil
+}
+
 func (s StmtDouble) Close() error {
 	return nil
 }
@@ -25,8 +45,10 @@ func (s StmtDouble) Step() (bool, error) {
 	return (step <= 2), nil
 }
 
+var exec error
+
 func (s StmtDouble) Exec(...interface{}) error {
-	return nil
+	return exec
 }
 
 func (s StmtDouble) Scan(dst ...interface{}) error {
@@ -35,18 +57,155 @@ func (s StmtDouble) Scan(dst ...interface{}) error {
 
 var repo = NewRepo(DataDouble{})
 
-func TestAllVehiclesByPeopleIDOK(t *testing.T) {
+func TestVehiclesByPeopleIDsGrouped(t *testing.T) {
 	step = 0
-	vs := repo.AllVehiclesByPeopleID(88)
-	if len(vs) != 2 {
-		t.Error("No vehicle for this people")
+	byPeople, err := repo.VehiclesByPeopleIDs([]int{88, 89})
+	if err != nil || len(byPeople) != 1 || len(byPeople[0]) != 2 {
+		t.Error("Vehicles not grouped by people")
 	}
 }
 
-func TestAllVehiclesByPeopleIDKO(t *testing.T) {
+func TestVehiclesByPeopleIDsNone(t *testing.T) {
+	step = 0
+	byPeople, err := repo.VehiclesByPeopleIDs(nil)
+	if err != nil || len(byPeople) != 0 {
+		t.Error("There's vehicle without people")
+	}
+}
+
+func TestVehiclesByFilmIDsGrouped(t *testing.T) {
+	step = 0
+	byFilm, err := repo.VehiclesByFilmIDs([]int{1, 2})
+	if err != nil || len(byFilm) != 1 || len(byFilm[0]) != 2 {
+		t.Error("Vehicles not grouped by film")
+	}
+}
+
+func TestVehiclesByFilmIDsNone(t *testing.T) {
+	byFilm, err := repo.VehiclesByFilmIDs(nil)
+	if err != nil || len(byFilm) != 0 {
+		t.Error("Vehicles without film")
+	}
+}
+
+func TestAllVehiclesOK(t *testing.T) {
+	step = 1
+	vs, _, err := repo.AllVehicles(database.NewPage(1, 10))
+	if err != nil || len(vs) != 1 {
+		t.Error("No vehicle")
+	}
+}
+
+func TestAllVehiclesKO(t *testing.T) {
 	step = 3
-	vs := repo.AllVehiclesByPeopleID(88)
-	if len(vs) != 0 {
-		t.Error("There's vehicle for this people")
+	vs, _, err := repo.AllVehicles(database.NewPage(1, 10))
+	if err != nil || len(vs) != 0 {
+		t.Error("There's vehicle")
+	}
+}
+
+func TestVehicleByIDOK(t *testing.T) {
+	step = 1
+	if _, err := repo.VehicleByID(10); err != nil {
+		t.Error("There's no vehicle with this id")
+	}
+}
+
+func TestVehicleByIDKO(t *testing.T) {
+	step = 2
+	if _, err := repo.VehicleByID(10); err == nil {
+		t.Error("There's vehicle with this id")
+	}
+}
+
+func TestVehicleByIDPilots(t *testing.T) {
+	step = 0
+	v, err := repo.VehicleByID(10)
+	if err != nil {
+		t.Fatal("There's no vehicle with this id")
+	}
+	if v.Pilots == nil || len(*v.Pilots) != 1 {
+		t.Error("No pilot for this vehicle")
+	}
+}
+
+func TestVehicleByIDNoPilot(t *testing.T) {
+	step = 1
+	v, err := repo.VehicleByID(10)
+	if err != nil {
+		t.Fatal("There's no vehicle with this id")
+	}
+	if b, _ := json.Marshal(v); !strings.Contains(string(b), `"pilots":[]`) {
+		t.Error("Pilots not listed : " + string(b))
+	}
+}
+
+func TestVehiclesByPeopleIDsNoPilots(t *testing.T) {
+	step = 1
+	byPeople, _ := repo.VehiclesByPeopleIDs([]int{88})
+	if b, _ := json.Marshal(byPeople[0]); strings.Contains(string(b), `"pilots"`) {
+		t.Error("Pilots of an embedded vehicle listed : " + string(b))
+	}
+}
+
+func TestPostVehicleFail(t *testing.T) {
+	step = 0
+	exec = errors.New("")
+	if _, err := repo.PostVehicle(Vehicle{Name: "Speeder bike"}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPostVehicleOK(t *testing.T) {
+	step = 0
+	exec = nil
+	if _, err := repo.PostVehicle(Vehicle{Name: "Speeder bike"}); err != nil {
+		t.Error("Post failed")
+	}
+}
+
+func TestPutVehicleNoVehicle(t *testing.T) {
+	step = 2
+	if err := repo.PutVehicle(10, Vehicle{}); err == nil {
+		t.Error("Found vehicle with this id")
+	}
+}
+
+func TestPutVehicleFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.PutVehicle(10, Vehicle{}); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestPutVehicleOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.PutVehicle(10, Vehicle{}); err != nil {
+		t.Error("Put failed")
+	}
+}
+
+func TestDeleteVehicleNoVehicle(t *testing.T) {
+	step = 2
+	if err := repo.DeleteVehicle(10); err == nil {
+		t.Error("Found vehicle with this id")
+	}
+}
+
+func TestDeleteVehicleFail(t *testing.T) {
+	step = 1
+	exec = errors.New("")
+	if err := repo.DeleteVehicle(10); err == nil {
+		t.Error("Fail exec")
+	}
+}
+
+func TestDeleteVehicleOK(t *testing.T) {
+	step = 1
+	exec = nil
+	if err := repo.DeleteVehicle(10); err != nil {
+		t.Error("Delete failed")
 	}
 }